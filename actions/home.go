@@ -18,33 +18,20 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/i18n"
 	"github.com/dlsniper/phas/sentry"
 	"github.com/dlsniper/phas/tts"
 )
 
 //SayHello will say hello to our users
-func SayHello(ctx context.Context, ttsService *tts.Service) error {
-	ttsService.Speak(ctx, "Hello, Human! How are you today?")
-	return nil
-}
-
-//SetLightsState will set the hue state depending on the user preference
-func SetLightsState(ctx context.Context, ttsService *tts.Service) error {
-	lightState := ctx.Value("phasLightsState")
-	myLightState, ok := lightState.(int)
-	if !ok {
-		ttsService.Speak(ctx, "I could not change the hue state.")
-		return errors.New("failed to set hue state")
-	}
-
-	ttsService.Speak(ctx, fmt.Sprintf("Changing the hue to state %d.", myLightState))
-
+func SayHello(ctx context.Context, _ *intents.Request, ttsService *tts.Service) error {
+	ttsService.Speak(ctx, i18n.Sprintf(ctx, "Hello, Human! How are you today?"))
 	return nil
 }
 
@@ -62,7 +49,7 @@ var httpClient = &http.Client{
 }
 
 //TellAJoke for the audience
-func TellAJoke(ctx context.Context, ttsService *tts.Service) error {
+func TellAJoke(ctx context.Context, _ *intents.Request, ttsService *tts.Service) error {
 	url := "https://official-joke-api.appspot.com/random_joke"
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
@@ -93,15 +80,12 @@ func TellAJoke(ctx context.Context, ttsService *tts.Service) error {
 	return nil
 }
 
-//SentryMode will set the hue state depending on the user preference
-func SentryMode(ctx context.Context, ttsService *tts.Service, s *sentry.Service) error {
-	sentryModeState := ctx.Value("sentryMode")
-	desiredSentryMode, ok := sentryModeState.(bool)
-	if !ok {
-		ttsService.Speak(ctx, "I could not set the sentry mode state.")
-		return errors.New("failed to sentry mode state")
+//SentryMode will turn the sentry mode on or off depending on the user preference.
+//The Service is nil when PHAS runs without the camera, e.g. in text mode.
+func SentryMode(ctx context.Context, ttsService *tts.Service, s *sentry.Service, desiredSentryMode bool) error {
+	if s == nil {
+		return errors.New("the sentry mode is not available")
 	}
-
 	s.Toggle(ctx, ttsService, desiredSentryMode)
 
 	return nil
@@ -0,0 +1,113 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package actions
+
+import (
+	"context"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/hue"
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/lights"
+	"github.com/dlsniper/phas/tts"
+)
+
+//SetLightsState will change the lights depending on the user preference, and tell the resulting state.
+//The room can be any light or group of lights, whatever its vendor, and when empty, all the lights are changed.
+func SetLightsState(ctx context.Context, ttsService *tts.Service, lightsService *lights.Service, room string, change lights.Change) error {
+	res, err := lightsService.Set(ctx, room, change)
+	if err != nil {
+		return err
+	}
+
+	sayLightsState(ctx, ttsService, res)
+	return nil
+}
+
+//ActivateScene will activate the hue scene, and tell the resulting state.
+//The room is optional, and only needed when several rooms have a scene with the same name.
+func ActivateScene(ctx context.Context, ttsService *tts.Service, hueService *hue.Service, scene, room string) error {
+	res, err := hueService.ActivateScene(ctx, scene, room)
+	if err != nil {
+		return err
+	}
+
+	sayLightsState(ctx, ttsService, res)
+	return nil
+}
+
+func sayLightsState(ctx context.Context, ttsService *tts.Service, res *lights.State) {
+	switch {
+	case res.All && !res.On:
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "The lights are off."))
+	case res.All:
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "The lights are on at %d percent.", res.Brightness))
+	case res.Light && !res.On:
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "The %s is off.", res.Name))
+	case res.Light:
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "The %s is on at %d percent.", res.Name, res.Brightness))
+	case !res.On:
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "The %s lights are off.", res.Name))
+	default:
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "The %s lights are on at %d percent.", res.Name, res.Brightness))
+	}
+}
+
+//ListLights will tell the lights or rooms of all the vendors, or the scenes known by the hue bridge
+func ListLights(ctx context.Context, ttsService *tts.Service, lightsService *lights.Service, hueService *hue.Service, what string) error {
+	var names []string
+	switch what {
+	case "scenes":
+		scenes, err := hueService.Scenes(ctx)
+		if err != nil {
+			return err
+		}
+		// Several rooms can have a scene with the same name
+		seen := map[string]bool{}
+		for _, sc := range scenes {
+			if !seen[sc.Name] {
+				seen[sc.Name] = true
+				names = append(names, sc.Name)
+			}
+		}
+	default:
+		devices, err := lightsService.Devices(ctx)
+		if err != nil {
+			return err
+		}
+		for _, d := range devices {
+			// The groups are the rooms
+			if d.Group == (what == "rooms") {
+				names = append(names, d.Name)
+			}
+		}
+	}
+
+	switch {
+	case len(names) == 0 && what == "rooms":
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "There are no rooms."))
+	case len(names) == 0 && what == "scenes":
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "There are no scenes."))
+	case len(names) == 0:
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "There are no lights."))
+	case what == "rooms":
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "You have %d rooms: %s.", len(names), intents.Join(ctx, names)))
+	case what == "scenes":
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "You have %d scenes: %s.", len(names), intents.Join(ctx, names)))
+	default:
+		ttsService.Speak(ctx, i18n.Sprintf(ctx, "You have %d lights: %s.", len(names), intents.Join(ctx, names)))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+)
+
+// commandTimeout limits how long a command can take before the client gets an error
+const commandTimeout = 2 * time.Minute
+
+type commandRequest struct {
+	Command  string `json:"command"`
+	Language string `json:"language,omitempty"`
+}
+
+type stepResponse struct {
+	Name     string `json:"name"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+type resultResponse struct {
+	Intent  string         `json:"intent"`
+	Phrase  string         `json:"phrase"`
+	Slots   intents.Slots  `json:"slots,omitempty"`
+	Score   float64        `json:"score"`
+	Steps   []stepResponse `json:"steps,omitempty"`
+	Aborted bool           `json:"aborted,omitempty"`
+	Skipped bool           `json:"skipped,omitempty"`
+}
+
+type commandResponse struct {
+	Command string           `json:"command"`
+	Results []resultResponse `json:"results"`
+}
+
+type intentResponse struct {
+	Command      string   `json:"command"`
+	Alternatives []string `json:"alternatives,omitempty"`
+	Language     string   `json:"language,omitempty"`
+	Category     string   `json:"category,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+//Server accepts text commands over HTTP and runs them through the commands pipeline
+type Server struct {
+	server   *http.Server
+	submit   func(*intents.Request)
+	registry *intents.Registry
+}
+
+//New creates a new Server listening on the given address, which hands the commands over to submit.
+//The registry provides the Intents listed by the API.
+func New(addr string, submit func(*intents.Request), registry *intents.Registry) *Server {
+	s := &Server{submit: submit, registry: registry}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/commands", s.handleCommand)
+	mux.HandleFunc("/intents", s.handleIntents)
+	s.server = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	return s
+}
+
+//ListenAndServe starts the Server and blocks until it is shut down
+func (s *Server) ListenAndServe() error {
+	log.Printf("starting the API server on %s\n", s.server.Addr)
+	err := s.server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+//Shutdown stops the Server once the running commands are done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("failed to write the API response: %v\n", err)
+	}
+}
+
+func (s *Server) handleCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use POST to send a command"})
+		return
+	}
+
+	cmd := commandRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request: " + err.Error()})
+		return
+	}
+	cmd.Command = strings.TrimSpace(cmd.Command)
+	if cmd.Command == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "the command is empty"})
+		return
+	}
+
+	req := &intents.Request{
+		Transcript: cmd.Command,
+		Language:   cmd.Language,
+		Source:     intents.SourceHTTP,
+		Done:       make(chan struct{}),
+	}
+	s.submit(req)
+
+	select {
+	case <-req.Done:
+	case <-r.Context().Done():
+		return
+	case <-time.After(commandTimeout):
+		writeJSON(w, http.StatusGatewayTimeout, errorResponse{Error: "the command did not finish in time"})
+		return
+	}
+
+	res := commandResponse{Command: cmd.Command, Results: []resultResponse{}}
+	for _, result := range req.Results {
+		rr := resultResponse{
+			Intent:  result.Intent,
+			Phrase:  result.Phrase,
+			Slots:   result.Slots,
+			Score:   result.Score,
+			Aborted: result.Aborted,
+			Skipped: result.Skipped,
+		}
+		for _, step := range result.Steps {
+			sr := stepResponse{Name: step.Name, Attempts: step.Attempts}
+			if step.Err != nil {
+				sr.Error = step.Err.Error()
+			}
+			rr.Steps = append(rr.Steps, sr)
+		}
+		res.Results = append(res.Results, rr)
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleIntents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use GET to list the intents"})
+		return
+	}
+
+	res := []intentResponse{}
+	for _, intent := range s.registry.List() {
+		res = append(res, intentResponse{
+			Command:      intent.Command,
+			Alternatives: intent.Alternatives,
+			Language:     intent.Language,
+			Category:     intent.Category,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,174 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dlsniper/phas/commands/intents"
+)
+
+// newTestServer serves the API, and handles the commands with handle
+func newTestServer(t *testing.T, handle func(*intents.Request)) *httptest.Server {
+	t.Helper()
+	registry := intents.NewRegistry()
+	err := registry.Replace([]*intents.Intent{
+		{Command: "turn on the lights", Alternatives: []string{"turn on the {room} lights"}, Category: "lights"},
+		{Command: "aprinde luminile", Language: "ro-RO", Priority: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New("", func(req *intents.Request) {
+		go func() {
+			handle(req)
+			close(req.Done)
+		}()
+	}, registry)
+	srv := httptest.NewServer(s.server.Handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCommand(t *testing.T) {
+	var got *intents.Request
+	srv := newTestServer(t, func(req *intents.Request) {
+		got = req
+		req.Results = append(req.Results, &intents.Result{
+			Intent: "turn on the lights",
+			Phrase: "turn on the {room} lights",
+			Slots:  intents.Slots{"room": "kitchen"},
+			Score:  1,
+			Steps: []intents.StepResult{
+				{Name: "lights", Attempts: 2, Err: errors.New("the bridge is not available")},
+			},
+			Aborted: true,
+		})
+	})
+
+	resp, err := http.Post(srv.URL+"/commands", "application/json",
+		strings.NewReader(`{"command": " turn on the kitchen lights ", "language": "en-GB"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got the status %s", resp.Status)
+	}
+
+	if got.Transcript != "turn on the kitchen lights" || got.Language != "en-GB" || got.Source != intents.SourceHTTP {
+		t.Errorf("got the request %+v", got)
+	}
+
+	var res commandResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	want := commandResponse{
+		Command: "turn on the kitchen lights",
+		Results: []resultResponse{{
+			Intent:  "turn on the lights",
+			Phrase:  "turn on the {room} lights",
+			Slots:   intents.Slots{"room": "kitchen"},
+			Score:   1,
+			Steps:   []stepResponse{{Name: "lights", Attempts: 2, Error: "the bridge is not available"}},
+			Aborted: true,
+		}},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("got the response %+v, want %+v", res, want)
+	}
+}
+
+func TestIntents(t *testing.T) {
+	srv := newTestServer(t, func(*intents.Request) {})
+
+	resp, err := http.Get(srv.URL + "/intents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got the status %s", resp.Status)
+	}
+
+	var res []intentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	// The higher priority comes first
+	want := []intentResponse{
+		{Command: "aprinde luminile", Language: "ro-RO"},
+		{Command: "turn on the lights", Alternatives: []string{"turn on the {room} lights"}, Category: "lights"},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("got the intents %+v, want %+v", res, want)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	submitted := false
+	srv := newTestServer(t, func(*intents.Request) { submitted = true })
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		allow  string
+	}{
+		{method: http.MethodGet, path: "/commands", status: http.StatusMethodNotAllowed, allow: http.MethodPost},
+		{method: http.MethodPost, path: "/commands", body: `{"command": `, status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/commands", body: `{"command": 42}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/commands", body: `{"command": "  "}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/intents", status: http.StatusMethodNotAllowed, allow: http.MethodGet},
+		{method: http.MethodGet, path: "/unknown", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s %s: got the status %s, want %d", tt.method, tt.path, tt.body, resp.Status, tt.status)
+		}
+		if resp.Header.Get("Allow") != tt.allow {
+			t.Errorf("%s %s: got Allow %q, want %q", tt.method, tt.path, resp.Header.Get("Allow"), tt.allow)
+		}
+		if tt.status != http.StatusNotFound {
+			var res errorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || res.Error == "" {
+				t.Errorf("%s %s %s: got no error message", tt.method, tt.path, tt.body)
+			}
+		}
+		resp.Body.Close()
+	}
+
+	if submitted {
+		t.Error("an invalid request was submitted as a command")
+	}
+}
@@ -0,0 +1,208 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Action is the outcome of one action of an intent
+type Action struct {
+	Name     string `json:"name"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+//Intent is an intent that ran for an interaction
+type Intent struct {
+	Intent  string            `json:"intent"`
+	Phrase  string            `json:"phrase,omitempty"`
+	Score   float64           `json:"score"`
+	Slots   map[string]string `json:"slots,omitempty"`
+	Actions []Action          `json:"actions,omitempty"`
+	Aborted bool              `json:"aborted,omitempty"`
+	Skipped bool              `json:"skipped,omitempty"`
+}
+
+//Entry records what PHAS heard and did for one interaction
+type Entry struct {
+	Time       time.Time        `json:"time"`
+	Source     string           `json:"source"`
+	WakeWord   string           `json:"wake_word,omitempty"`
+	Language   string           `json:"language,omitempty"`
+	Transcript string           `json:"transcript"`
+	Intents    []Intent         `json:"intents"`
+	Latencies  map[string]int64 `json:"latencies_ms,omitempty"`
+}
+
+//Matches tells if any of the intents of the Entry contains the given text
+func (e Entry) Matches(intent string) bool {
+	if intent == "" {
+		return true
+	}
+	intent = strings.ToLower(intent)
+	for _, i := range e.Intents {
+		if strings.Contains(strings.ToLower(i.Intent), intent) {
+			return true
+		}
+	}
+	return false
+}
+
+//Log writes the entries as JSON lines to a file, which is rotated once it grows past a maximum size
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+//Open opens the log file for appending. At most maxFiles rotated files of about maxSize bytes are kept.
+func Open(path string, maxSize int64, maxFiles int) (*Log, error) {
+	l := &Log{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	l.file = f
+	l.size = fi.Size()
+	return nil
+}
+
+func rotatedName(path string, idx int) string {
+	return fmt.Sprintf("%s.%d", path, idx)
+}
+
+// rotate moves the current file to path.1, path.1 to path.2, and so on
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+
+	_ = os.Remove(rotatedName(l.path, l.maxFiles))
+	for idx := l.maxFiles - 1; idx >= 1; idx-- {
+		if err := os.Rename(rotatedName(l.path, idx), rotatedName(l.path, idx+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if l.maxFiles > 0 {
+		if err := os.Rename(l.path, rotatedName(l.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+
+	return l.open()
+}
+
+//Write appends the Entry to the log
+func (l *Log) Write(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+//Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+//Query reads the entries of the log, including the rotated files, which happened between from and to and ran the given intent.
+//A zero time or an empty intent are not used as filters.
+func Query(path string, maxFiles int, from, to time.Time, intent string) ([]Entry, error) {
+	var files []string
+	for idx := maxFiles; idx >= 1; idx-- {
+		files = append(files, rotatedName(path, idx))
+	}
+	files = append(files, path)
+
+	var res []Entry
+	for _, name := range files {
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			e := Entry{}
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				continue
+			}
+			if !from.IsZero() && e.Time.Before(from) {
+				continue
+			}
+			if !to.IsZero() && e.Time.After(to) {
+				continue
+			}
+			if e.Matches(intent) {
+				res = append(res, e)
+			}
+		}
+		err = scanner.Err()
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
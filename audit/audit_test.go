@@ -0,0 +1,173 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// start is the time of the first test entry, and each next entry is a minute later
+var start = time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+// entry returns the test entry with the given index. All of them have the same size.
+func entry(idx int) Entry {
+	intent := "open the door"
+	if idx%2 == 1 {
+		intent = "lock the door"
+	}
+	return Entry{
+		Time:       start.Add(time.Duration(idx) * time.Minute),
+		Source:     "text",
+		Transcript: intent,
+		Intents:    []Intent{{Intent: intent, Score: 1}},
+	}
+}
+
+func entrySize(t *testing.T) int64 {
+	t.Helper()
+	b, err := json.Marshal(entry(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int64(len(b)) + 1
+}
+
+func indexes(entries []Entry) []int {
+	var res []int
+	for _, e := range entries {
+		res = append(res, int(e.Time.Sub(start)/time.Minute))
+	}
+	return res
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeEntries writes the entries from..to-1 to a log which rotates every two entries, and keeps two rotated files
+func writeEntries(t *testing.T, path string, from, to int) {
+	t.Helper()
+	l, err := Open(path, 2*entrySize(t), 2)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+	for idx := from; idx < to; idx++ {
+		if err := l.Write(entry(idx)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeEntries(t, path, 0, 3)
+	// The log is opened again, as after a restart, and keeps the size of the file
+	writeEntries(t, path, 3, 7)
+
+	files := map[string][]int{
+		path:        {6},
+		path + ".1": {4, 5},
+		path + ".2": {2, 3},
+	}
+	for name, want := range files {
+		entries, err := Query(name, 0, time.Time{}, time.Time{}, "")
+		if err != nil {
+			t.Fatalf("Query of %s failed: %v", name, err)
+		}
+		if got := indexes(entries); !equal(got, want) {
+			t.Errorf("%s has the entries %v, want %v", name, got, want)
+		}
+	}
+	// The oldest entries are removed
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want at most 2 rotated files", path)
+	}
+}
+
+func TestRotationWithoutFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, entrySize(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for idx := 0; idx < 3; idx++ {
+		if err := l.Write(entry(idx)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	entries, err := Query(path, 0, time.Time{}, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := indexes(entries); !equal(got, []int{2}) {
+		t.Errorf("got the entries %v, want only the last one", got)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeEntries(t, path, 0, 7)
+	// A line which is not an entry is skipped
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("not json\n")
+	f.Close()
+
+	at := func(idx int) time.Time {
+		return start.Add(time.Duration(idx) * time.Minute)
+	}
+	tests := []struct {
+		name   string
+		from   time.Time
+		to     time.Time
+		intent string
+		want   []int
+	}{
+		{name: "all", want: []int{2, 3, 4, 5, 6}},
+		{name: "from", from: at(4), want: []int{4, 5, 6}},
+		{name: "to", to: at(3), want: []int{2, 3}},
+		{name: "range", from: at(3), to: at(5), want: []int{3, 4, 5}},
+		{name: "intent", intent: "LOCK", want: []int{3, 5}},
+		{name: "intent and range", from: at(3), to: at(5), intent: "open the door", want: []int{4}},
+		{name: "unknown intent", intent: "lights"},
+	}
+
+	for _, tt := range tests {
+		entries, err := Query(path, 2, tt.from, tt.to, tt.intent)
+		if err != nil {
+			t.Fatalf("%s: Query failed: %v", tt.name, err)
+		}
+		if got := indexes(entries); !equal(got, tt.want) {
+			t.Errorf("%s: got the entries %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
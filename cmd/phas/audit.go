@@ -0,0 +1,93 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/audit"
+)
+
+// auditFile returns the path of the interactions audit log
+func auditFile() string {
+	if path := os.Getenv("PHAS_AUDIT_FILE"); path != "" {
+		return path
+	}
+	return "phas-audit.jsonl"
+}
+
+// queryAudit prints the audit log entries that match the command line filters
+func queryAudit(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	since := fs.Duration("since", 0, "only show the interactions from this long ago, e.g. 2h")
+	from := fs.String("from", "", "only show the interactions after this time, in RFC3339 format")
+	to := fs.String("to", "", "only show the interactions before this time, in RFC3339 format")
+	intent := fs.String("intent", "", "only show the interactions whose intent contains this text")
+	asJSON := fs.Bool("json", false, "print the entries as JSON lines")
+	_ = fs.Parse(args)
+
+	var fromTime, toTime time.Time
+	var err error
+	if *since > 0 {
+		fromTime = time.Now().Add(-*since)
+	}
+	if *from != "" {
+		if fromTime, err = time.Parse(time.RFC3339, *from); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if *to != "" {
+		if toTime, err = time.Parse(time.RFC3339, *to); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	entries, err := audit.Query(auditFile(), auditMaxFiles, fromTime, toTime, *intent)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range entries {
+		if *asJSON {
+			_ = enc.Encode(e)
+			continue
+		}
+
+		var outcomes []string
+		for _, i := range e.Intents {
+			outcome := "ok"
+			switch {
+			case i.Skipped:
+				outcome = "skipped"
+			case i.Aborted:
+				outcome = "aborted"
+			}
+			for _, a := range i.Actions {
+				if a.Error != "" {
+					outcome = "failed: " + a.Error
+				}
+			}
+			outcomes = append(outcomes, fmt.Sprintf("%q (%.2f) %s", i.Intent, i.Score, outcome))
+		}
+		fmt.Printf("%s %-8s %q -> %s\n", e.Time.Format(time.RFC3339), e.Source, e.Transcript, strings.Join(outcomes, ", "))
+	}
+}
@@ -0,0 +1,112 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/config"
+	"github.com/dlsniper/phas/hue"
+)
+
+// hueCommand runs the hue subcommands
+func hueCommand(ctx context.Context, args []string) {
+	if len(args) == 0 || args[0] != "pair" {
+		fmt.Fprintf(os.Stderr, "usage: %s hue pair [-addr address] [-discovery-url url] [-timeout duration]\n", os.Args[0])
+		os.Exit(2)
+	}
+	pairHue(ctx, args[1:])
+}
+
+// pairHue finds a hue bridge, creates a user on it, and saves it in the configuration file
+func pairHue(ctx context.Context, args []string) {
+	discoveryURL := os.Getenv("PHAS_HUE_DISCOVERY_URL")
+	if discoveryURL == "" {
+		discoveryURL = hue.DiscoveryURL
+	}
+
+	fs := flag.NewFlagSet("hue pair", flag.ExitOnError)
+	address := fs.String("addr", "", "the address of the bridge, instead of discovering it")
+	fs.StringVar(&discoveryURL, "discovery-url", discoveryURL, "the discovery service to use besides SSDP, or empty for none")
+	timeout := fs.Duration("timeout", time.Minute, "how long to wait for the link button to be pressed")
+	_ = fs.Parse(args)
+
+	bridge := hue.Bridge{Address: *address}
+	if bridge.Address == "" {
+		fmt.Println("Looking for hue bridges...")
+		bridges, err := hue.Discover(ctx, discoveryURL, 3*time.Second)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		bridge = chooseBridge(bridges)
+	}
+
+	hostname, _ := os.Hostname()
+	name := "phas#" + hostname
+	// The bridge accepts at most 40 characters
+	if len(name) > 40 {
+		name = name[:40]
+	}
+
+	pairCtx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	paired, err := hue.Pair(pairCtx, bridge.Address, name, 2*time.Second, func() {
+		fmt.Printf("Press the link button on the hue bridge at %s. Waiting for %s...\n", bridge.Address, *timeout)
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	paired.ID = bridge.ID
+
+	configPath := configFile()
+	if err := config.Set(configPath, "hue", paired); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Paired with the hue bridge at %s. Its user was saved to %s.\n", paired.Address, configPath)
+}
+
+// chooseBridge asks the user which bridge to pair, when there are several of them
+func chooseBridge(bridges []hue.Bridge) hue.Bridge {
+	switch len(bridges) {
+	case 0:
+		log.Fatalln("no hue bridge found, use -addr to pair with a bridge at a known address")
+	case 1:
+		fmt.Printf("Found the hue bridge %s at %s.\n", bridges[0].ID, bridges[0].Address)
+		return bridges[0]
+	}
+
+	fmt.Println("Found several hue bridges:")
+	for idx, b := range bridges {
+		fmt.Printf("%d. %s at %s\n", idx+1, b.ID, b.Address)
+	}
+	fmt.Print("Which one should be paired? ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalln(err)
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(bridges) {
+		log.Fatalf("the choice must be a number between 1 and %d\n", len(bridges))
+	}
+	return bridges[choice-1]
+}
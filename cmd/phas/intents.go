@@ -16,87 +16,453 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dlsniper/phas/actions"
 	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/config"
+	"github.com/dlsniper/phas/hue"
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/lights"
+	"github.com/dlsniper/phas/normalize"
+	"github.com/dlsniper/phas/plugins"
+	"github.com/dlsniper/phas/routines"
+	"github.com/dlsniper/phas/scheduler"
 	"github.com/dlsniper/phas/sentry"
 	"github.com/dlsniper/phas/tts"
 )
 
-func registerIntents(s *sentry.Service) {
-	myIntents := []*intents.Intent{
-		{
-			Command: "turn the lights on",
-			Alternatives: []string{
-				"turn on the lights",
-			},
-			Actions: []intents.Action{
-				func(ctx context.Context, ttsService *tts.Service) error {
-					ctx = context.WithValue(ctx, "phasLightsState", 255)
-					return actions.SetLightsState(ctx, ttsService)
-				},
-			},
+// defaultIntents are used when the configuration file does not define any intents
+var defaultIntents = []intents.Definition{
+	{
+		Command:  "turn the lights on",
+		Category: "lights",
+		Alternatives: []string{
+			"turn on the lights",
+			"turn on the {room} lights",
+			"turn the {room} lights on",
+			"turn on the lights in the {room}",
+			"turn them on",
+			"turn it on",
 		},
-		{
-			Command: "turn the lights off",
-			Alternatives: []string{
-				"turn off the lights",
-			},
-			Actions: []intents.Action{
-				func(ctx context.Context, ttsService *tts.Service) error {
-					ctx = context.WithValue(ctx, "phasLightsState", 0)
-					return actions.SetLightsState(ctx, ttsService)
-				},
-			},
+		Actions: []intents.ActionDefinition{{Name: "lights", Params: intents.Params{"state": "255"}}},
+	},
+	{
+		Command:  "turn the lights off",
+		Category: "lights",
+		Alternatives: []string{
+			"turn off the lights",
+			"turn off the {room} lights",
+			"turn the {room} lights off",
+			"turn off the lights in the {room}",
+			"turn them off",
+			"turn it off",
 		},
-		{
-			Command: "dim the lights",
-			Actions: []intents.Action{
-				func(ctx context.Context, ttsService *tts.Service) error {
-					ctx = context.WithValue(ctx, "phasLightsState", 70)
-					return actions.SetLightsState(ctx, ttsService)
-				},
-			},
+		Actions: []intents.ActionDefinition{{Name: "lights", Params: intents.Params{"state": "0"}}},
+	},
+	{
+		Command:      "dim the lights",
+		Category:     "lights",
+		Alternatives: []string{"dim the {room} lights", "dim them", "dim it"},
+		Actions:      []intents.ActionDefinition{{Name: "lights", Params: intents.Params{"level": "30 percent"}}},
+	},
+	{
+		Command:      "set the lights to half",
+		Category:     "lights",
+		Priority:     2,
+		Alternatives: []string{"set the {room} lights to half", "set the {room} to half", "set them to half"},
+		Actions:      []intents.ActionDefinition{{Name: "lights", Params: intents.Params{"level": "half"}}},
+	},
+	{
+		Command:  "set the lights to {level} percent",
+		Category: "lights",
+		// Before "set the {room} to {color}", which would take "lights" for the room and the level for the color
+		Priority: 2,
+		Alternatives: []string{
+			"dim the lights to {level} percent",
+			"set the {room} lights to {level} percent",
+			"set the {room} to {level} percent",
+			"dim the {room} lights to {level} percent",
+			"set them to {level} percent",
+			"dim them to {level} percent",
+			"dim the lights to {level}",
+			"set the lights to {level}%",
+			"set the {room} lights to {level}%",
+			// These also take colors, such as "set the lights to red", which lights_level passes on
+			"set the lights to {level}",
+			"set the {room} lights to {level}",
 		},
-		{
-			Command: "turn on the sentry mode",
-			Actions: []intents.Action{
-				func(ctx context.Context, ttsService *tts.Service) error {
-					ctx = context.WithValue(ctx, "sentryMode", true)
-					return actions.SentryMode(ctx, ttsService, s)
-				},
-			},
+		Actions: []intents.ActionDefinition{{Name: "lights_level"}},
+	},
+	{
+		Command:  "set the lights level",
+		Category: "lights",
+		Actions:  []intents.ActionDefinition{{Name: "lights_level"}},
+		Prompts:  map[string]string{"level": "To what percent should I set the lights?"},
+	},
+	{
+		Command:      "fade out the lights",
+		Category:     "lights",
+		Alternatives: []string{"fade out the {room} lights", "fade out the lights in the {room}", "fade them out", "fade it out"},
+		Actions: []intents.ActionDefinition{{
+			Name:   "lights",
+			Params: intents.Params{"state": "0", "transition": "30 seconds"},
+		}},
+	},
+	{
+		Command:  "activate the {scene} scene",
+		Category: "lights",
+		Priority: 1,
+		Alternatives: []string{
+			"activate the {scene} scene in the {room}",
+			"set the {room} to the {scene} scene",
+			"turn on the {scene} scene",
+			"start the {scene} scene",
 		},
-		{
-			Command: "turn off the sentry mode",
-			Actions: []intents.Action{
-				func(ctx context.Context, ttsService *tts.Service) error {
-					ctx = context.WithValue(ctx, "sentryMode", false)
-					return actions.SentryMode(ctx, ttsService, s)
-				},
-			},
-		},
-		{
-			Command: "tell me a joke",
-			Alternatives: []string{
-				"tell a joke",
-			},
-			Actions: []intents.Action{
-				actions.TellAJoke,
-			},
-		},
-		{
-			Command: "say hello",
-			Alternatives: []string{
-				"say hi",
-			},
-			Actions: []intents.Action{
-				actions.SayHello,
-			},
+		Actions: []intents.ActionDefinition{{Name: "lights_scene"}},
+	},
+	{
+		Command:  "set the {room} to {color}",
+		Category: "lights",
+		Alternatives: []string{
+			"make the lights {color}",
+			"change the lights to {color}",
+			"make the {room} lights {color}",
+			"change the {room} lights to {color}",
+			"make them {color}",
+			"make it {color}",
+			"set them to {color}",
 		},
+		Actions: []intents.ActionDefinition{{Name: "lights_color"}},
+	},
+	{
+		Command:      "what lights do i have",
+		Category:     "lights",
+		Alternatives: []string{"list the lights", "which lights do i have"},
+		Actions:      []intents.ActionDefinition{{Name: "lights_list", Params: intents.Params{"what": "lights"}}},
+	},
+	{
+		Command:      "what rooms do i have",
+		Category:     "lights",
+		Alternatives: []string{"list the rooms", "which rooms do i have"},
+		Actions:      []intents.ActionDefinition{{Name: "lights_list", Params: intents.Params{"what": "rooms"}}},
+	},
+	{
+		Command:      "what scenes do i have",
+		Category:     "lights",
+		Alternatives: []string{"list the scenes", "which scenes do i have"},
+		Actions:      []intents.ActionDefinition{{Name: "lights_list", Params: intents.Params{"what": "scenes"}}},
+	},
+	{
+		Command:  "turn on the sentry mode",
+		Category: "sentry mode",
+		Actions:  []intents.ActionDefinition{{Name: "sentry", Params: intents.Params{"mode": "on"}}},
+	},
+	{
+		Command:  "turn off the sentry mode",
+		Category: "sentry mode",
+		Actions:  []intents.ActionDefinition{{Name: "sentry", Params: intents.Params{"mode": "off"}}},
+	},
+	{
+		Command:      "stop the alarm",
+		Category:     "sentry mode",
+		Alternatives: []string{"turn off the alarm", "silence the alarm"},
+		Actions:      []intents.ActionDefinition{{Name: "lights_alarm_stop"}},
+	},
+	{
+		Command:      "tell me a joke",
+		Category:     "fun",
+		Alternatives: []string{"tell a joke"},
+		Actions:      []intents.ActionDefinition{{Name: "joke"}},
+	},
+	{
+		Command:      "say hello",
+		Category:     "fun",
+		Alternatives: []string{"say hi"},
+		Actions:      []intents.ActionDefinition{{Name: "hello"}},
+	},
+}
+
+func registerActions(s *sentry.Service, lightsService *lights.Service, hueService *hue.Service) {
+	intents.RegisterAction("lights", func(params intents.Params) (intents.Action, error) {
+		var state int
+		if params["level"] != "" {
+			level, err := normalize.Percent(params["level"])
+			if err != nil {
+				return nil, err
+			}
+			state = level * 255 / 100
+		} else {
+			var err error
+			state, err = strconv.Atoi(params["state"])
+			if err != nil || state < 0 || state > 255 {
+				return nil, fmt.Errorf("the state must be a number between 0 and 255, got %q", params["state"])
+			}
+		}
+		d, err := transition(params)
+		if err != nil {
+			return nil, err
+		}
+		change := lights.Change{Off: state == 0, Brightness: state, Transition: d}
+		return func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+			return actions.SetLightsState(ctx, ttsService, lightsService, req.Slot("room"), change)
+		}, nil
+	})
+
+	intents.RegisterAction("lights_level", func(params intents.Params) (intents.Action, error) {
+		slotName := params["slot"]
+		if slotName == "" {
+			slotName = "level"
+		}
+		d, err := transition(params)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+			change, err := levelChange(req.Slot(slotName))
+			if err != nil {
+				return err
+			}
+			change.Transition = d
+			return actions.SetLightsState(ctx, ttsService, lightsService, req.Slot("room"), change)
+		}, nil
+	})
+
+	intents.RegisterAction("lights_color", func(params intents.Params) (intents.Action, error) {
+		slotName := params["slot"]
+		if slotName == "" {
+			slotName = "color"
+		}
+		d, err := transition(params)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+			color := params["color"]
+			if color == "" {
+				color = req.Slot(slotName)
+			}
+			change, err := colorChange(color)
+			if err != nil {
+				return err
+			}
+			change.Transition = d
+			return actions.SetLightsState(ctx, ttsService, lightsService, req.Slot("room"), change)
+		}, nil
+	})
+
+	intents.RegisterAction("lights_scene", func(params intents.Params) (intents.Action, error) {
+		return func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+			scene := params["scene"]
+			if scene == "" {
+				scene = req.Slot("scene")
+			}
+			return actions.ActivateScene(ctx, ttsService, hueService, scene, req.Slot("room"))
+		}, nil
+	})
+
+	intents.RegisterAction("lights_list", func(params intents.Params) (intents.Action, error) {
+		what := params["what"]
+		switch what {
+		case "":
+			what = "lights"
+		case "lights", "rooms", "scenes":
+		default:
+			return nil, fmt.Errorf("only lights, rooms or scenes can be listed, not %q", what)
+		}
+		return func(ctx context.Context, _ *intents.Request, ttsService *tts.Service) error {
+			return actions.ListLights(ctx, ttsService, lightsService, hueService, what)
+		}, nil
+	})
+
+	intents.RegisterAction("sentry", func(params intents.Params) (intents.Action, error) {
+		var mode bool
+		switch params["mode"] {
+		case "on":
+			mode = true
+		case "off":
+			mode = false
+		default:
+			return nil, fmt.Errorf("the mode must be on or off, got %q", params["mode"])
+		}
+		return func(ctx context.Context, _ *intents.Request, ttsService *tts.Service) error {
+			return actions.SentryMode(ctx, ttsService, s, mode)
+		}, nil
+	})
+
+	intents.RegisterAction("say", func(params intents.Params) (intents.Action, error) {
+		text := params["text"]
+		if text == "" {
+			return nil, fmt.Errorf("the text to say is missing")
+		}
+		return func(ctx context.Context, _ *intents.Request, ttsService *tts.Service) error {
+			ttsService.Speak(ctx, text)
+			return nil
+		}, nil
+	})
+
+	intents.RegisterAction("wait", func(params intents.Params) (intents.Action, error) {
+		d, err := normalize.Duration(params["duration"])
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, _ *intents.Request, _ *tts.Service) error {
+			t := time.NewTimer(d)
+			defer t.Stop()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-t.C:
+				return nil
+			}
+		}, nil
+	})
+
+	intents.RegisterAction("lights_alarm", func(params intents.Params) (intents.Action, error) {
+		group := params["group"]
+		if group == "" {
+			return nil, fmt.Errorf("the lights group is missing")
+		}
+		pattern := params["pattern"]
+		return func(ctx context.Context, _ *intents.Request, _ *tts.Service) error {
+			// The alarm runs in the background, after the intent is done
+			return lightsService.Alarm(context.Background(), group, pattern)
+		}, nil
+	})
+
+	intents.RegisterAction("lights_alarm_stop", func(intents.Params) (intents.Action, error) {
+		return func(ctx context.Context, _ *intents.Request, ttsService *tts.Service) error {
+			lightsService.StopAlarm()
+			ttsService.Speak(ctx, i18n.Sprintf(ctx, "The alarm is off."))
+			return nil
+		}, nil
+	})
+
+	intents.RegisterAction("joke", func(intents.Params) (intents.Action, error) {
+		return actions.TellAJoke, nil
+	})
+
+	intents.RegisterAction("hello", func(intents.Params) (intents.Action, error) {
+		return actions.SayHello, nil
+	})
+}
+
+func registerChecks(s *sentry.Service) {
+	started := func() bool {
+		return s != nil && s.Started()
+	}
+	routines.RegisterCheck("sentry_on", started)
+	routines.RegisterCheck("sentry_off", func() bool {
+		return !started()
+	})
+}
+
+// loadIntents replaces the intents of the registry and the alarms of the lights with the ones from the configuration.
+// When any of them is invalid, the current ones are kept.
+func loadIntents(registry *intents.Registry, cfg *config.Config, runner *routines.Runner, sched *scheduler.Service, pluginManager *plugins.Manager, lightsService *lights.Service) error {
+	alarms, err := lights.BuildAlarms(cfg.Alarms)
+	if err != nil {
+		return err
+	}
+
+	defs := cfg.Intents
+	if len(defs) == 0 {
+		log.Println("no intents configured, using the default intents")
+		defs = defaultIntents
 	}
+	defs = append(append([]intents.Definition{}, defs...), timerIntents...)
 
-	for _, intent := range myIntents {
-		intents.RegisterIntent(intent)
+	myIntents, err := intents.BuildAll(defs)
+	if err != nil {
+		return err
+	}
+	builtins := append([]*intents.Intent{runner.CancelIntent()}, registry.HelpIntents()...)
+
+	// A plugin whose phrases are taken by the configured or built-in intents is skipped
+	pluginIntents, err := pluginManager.Load(context.Background(), cfg.Plugins, append(append([]*intents.Intent{}, myIntents...), builtins...))
+	if err != nil {
+		return err
+	}
+	// The new plugins are only used when the whole configuration is valid
+	committed := false
+	defer func() {
+		if !committed {
+			pluginManager.Discard()
+		}
+	}()
+	myIntents = append(myIntents, pluginIntents...)
+
+	for _, def := range cfg.Routines {
+		routine, err := runner.Build(def, myIntents)
+		if err != nil {
+			return err
+		}
+		myIntents = append(myIntents, routine)
+	}
+	myIntents = append(myIntents, builtins...)
+	if err := intents.CheckPhrases(myIntents); err != nil {
+		return err
+	}
+
+	schedule, err := scheduler.Build(cfg.Schedules, myIntents)
+	if err != nil {
+		return err
+	}
+
+	// Everything is valid, so the new configuration replaces the current one at once
+	if err := registry.Replace(myIntents); err != nil {
+		return err
+	}
+	pluginManager.Commit()
+	committed = true
+	lightsService.ReplaceAlarms(alarms)
+	sched.Set(schedule)
+	i18n.Configure(cfg.Translations)
+	log.Printf("registered %d intents\n", len(myIntents))
+	return nil
+}
+
+// transition reads the optional transition time of the lights actions
+func transition(params intents.Params) (time.Duration, error) {
+	if params["transition"] == "" {
+		return 0, nil
+	}
+	return normalize.Duration(params["transition"])
+}
+
+// levelChange converts a spoken level, such as "50" or "half", to a change of the lights.
+// The phrases without "percent" also catch colors, so a level which isn't a number is taken as a color.
+func levelChange(level string) (lights.Change, error) {
+	value, err := normalize.Percent(level)
+	if err == nil {
+		return lights.Change{Off: value == 0, Brightness: value * 255 / 100}, nil
+	}
+	lower := strings.ToLower(strings.TrimSpace(level))
+	if strings.HasSuffix(lower, "kelvin") {
+		return colorChange(level)
+	}
+	if _, err := normalize.Number(lower); err != nil && !strings.ContainsAny(lower, "%0123456789") && !strings.Contains(lower, "percent") {
+		return colorChange(level)
+	}
+	return lights.Change{}, fmt.Errorf("the lights can only be set between 0 and 100 percent, not %q", level)
+}
+
+// colorChange converts a spoken color, such as "red" or "2700 kelvin", to a change of the lights
+func colorChange(color string) (lights.Change, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if strings.HasSuffix(color, "kelvin") {
+		kelvin, err := normalize.Number(strings.TrimSuffix(color, "kelvin"))
+		if err != nil || kelvin < 1 {
+			return lights.Change{}, fmt.Errorf("the color temperature must be a number of kelvin, not %q", color)
+		}
+		return lights.Change{Kelvin: int(kelvin)}, nil
+	}
+	if _, err := normalize.Percent(color); err == nil {
+		return lights.Change{}, fmt.Errorf("%q is a brightness, not a color", color)
 	}
+	return lights.Change{Color: color}, nil
 }
@@ -0,0 +1,94 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/lights"
+)
+
+func TestDefaultIntents(t *testing.T) {
+	registerActions(nil, nil, nil)
+	registerTimerActions(nil)
+	list, err := intents.BuildAll(append(append([]intents.Definition{}, defaultIntents...), timerIntents...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := intents.NewRegistry()
+	if err := registry.Replace(list); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command string
+		intent  string
+		slots   intents.Slots
+	}{
+		{command: "turn off the lights", intent: "turn the lights off"},
+		{command: "turn off the lights in the kitchen", intent: "turn the lights off", slots: intents.Slots{"room": "kitchen"}},
+		{command: "turn on the lights in the living room", intent: "turn the lights on", slots: intents.Slots{"room": "living room"}},
+		{command: "set the lights to 50 percent", intent: "set the lights to {level} percent", slots: intents.Slots{"level": "50"}},
+		{command: "set the lights to half", intent: "set the lights to half"},
+		{command: "set the kitchen lights to 40%", intent: "set the lights to {level} percent", slots: intents.Slots{"room": "kitchen", "level": "40"}},
+		{command: "set the kitchen to 50 percent", intent: "set the lights to {level} percent", slots: intents.Slots{"room": "kitchen", "level": "50"}},
+		{command: "set the kitchen to red", intent: "set the {room} to {color}", slots: intents.Slots{"room": "kitchen", "color": "red"}},
+		{command: "set the lights to 50", intent: "set the lights to {level} percent", slots: intents.Slots{"level": "50"}},
+		{command: "set the kitchen lights to 50", intent: "set the lights to {level} percent", slots: intents.Slots{"room": "kitchen", "level": "50"}},
+		{command: "set the lights to red", intent: "set the lights to {level} percent", slots: intents.Slots{"level": "red"}},
+		{command: "set them to warm white", intent: "set the {room} to {color}", slots: intents.Slots{"color": "warm white"}},
+		{command: "set them to half", intent: "set the lights to half"},
+		{command: "set the kitchen to the relax scene", intent: "activate the {scene} scene", slots: intents.Slots{"room": "kitchen", "scene": "relax"}},
+		{command: "activate the relax scene", intent: "activate the {scene} scene", slots: intents.Slots{"scene": "relax"}},
+	}
+
+	for _, tt := range tests {
+		match := registry.Match(context.Background(), tt.command)
+		if match.Intent.Command != tt.intent || match.Score != 1 {
+			t.Errorf("%q matched %q with score %v, want %q", tt.command, match.Intent.Command, match.Score, tt.intent)
+			continue
+		}
+		for name, value := range tt.slots {
+			if match.Slots[name] != value {
+				t.Errorf("%q slot %s = %q, want %q", tt.command, name, match.Slots[name], value)
+			}
+		}
+	}
+}
+
+func TestLevelChange(t *testing.T) {
+	tests := []struct {
+		level  string
+		change lights.Change
+		err    bool
+	}{
+		{level: "50", change: lights.Change{Brightness: 127}},
+		{level: "half", change: lights.Change{Brightness: 127}},
+		{level: "0", change: lights.Change{Off: true}},
+		{level: "red", change: lights.Change{Color: "red"}},
+		{level: "2700 kelvin", change: lights.Change{Kelvin: 2700}},
+		{level: "150", err: true},
+		{level: "150 percent", err: true},
+	}
+
+	for _, tt := range tests {
+		change, err := levelChange(tt.level)
+		if (err != nil) != tt.err || change != tt.change {
+			t.Errorf("levelChange(%q) = %+v, %v, want %+v", tt.level, change, err, tt.change)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// defaultVoices are used for the languages without a voice in PHAS_TTS_VOICES
+var defaultVoices = map[string]string{
+	"en-US": "en-US-Wavenet-D",
+	"ro-RO": "ro-RO-Wavenet-A",
+}
+
+// alternativeLanguages returns the languages recognized besides the main one, e.g. PHAS_ALTERNATIVE_LANGUAGES=ro-RO,fr-FR
+func alternativeLanguages() []string {
+	var res []string
+	for _, language := range strings.Split(os.Getenv("PHAS_ALTERNATIVE_LANGUAGES"), ",") {
+		if language = strings.TrimSpace(language); language != "" {
+			res = append(res, language)
+		}
+	}
+	return res
+}
+
+// voices returns the voice of each language, e.g. PHAS_TTS_VOICES=en-US=en-US-Wavenet-D,ro-RO=ro-RO-Wavenet-A
+func voices() map[string]string {
+	res := map[string]string{}
+	for language, voice := range defaultVoices {
+		res[language] = voice
+	}
+
+	for _, entry := range strings.Split(os.Getenv("PHAS_TTS_VOICES"), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			log.Fatalf("invalid voice %q in PHAS_TTS_VOICES, the format is language=voice\n", entry)
+		}
+		res[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return res
+}
@@ -16,6 +16,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"math/rand"
 	"os"
@@ -23,14 +24,27 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/dlsniper/phas/api"
+	"github.com/dlsniper/phas/audit"
 	"github.com/dlsniper/phas/commands"
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/config"
+	"github.com/dlsniper/phas/dialog"
 	"github.com/dlsniper/phas/gcp"
 	"github.com/dlsniper/phas/hue"
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/lights"
+	"github.com/dlsniper/phas/mqtt"
+	"github.com/dlsniper/phas/plugins"
+	"github.com/dlsniper/phas/routines"
 	"github.com/dlsniper/phas/rv"
+	"github.com/dlsniper/phas/scheduler"
 	"github.com/dlsniper/phas/sentry"
 	"github.com/dlsniper/phas/sms"
 	"github.com/dlsniper/phas/stt"
+	"github.com/dlsniper/phas/timers"
 	"github.com/dlsniper/phas/tts"
+	"github.com/dlsniper/phas/wled"
 )
 
 func main() {
@@ -38,19 +52,109 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	ctx := context.Background()
 
-	wait := make(chan struct{})
-	userCommands := make(chan string, 10)
+	if language := os.Getenv("PHAS_LANGUAGE"); language != "" {
+		i18n.Default = language
+	}
 
+	mode := "listen"
+	if len(os.Args) > 1 {
+		mode = os.Args[1]
+	}
+
+	switch mode {
+	case "listen":
+		listen(ctx)
+	case "repl":
+		repl(ctx)
+	case "audit":
+		queryAudit(os.Args[2:])
+	case "hue":
+		hueCommand(ctx, os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "usage: %s [listen|repl|audit|hue]\n", os.Args[0])
+		os.Exit(2)
+	}
+}
+
+// listen runs PHAS with the wakeword, microphone, and GCP voice services
+func listen(ctx context.Context) {
 	wwListener := initializeWakeWordListener()
 
 	sttClient, ttsClient := gcp.InitServices(ctx)
-	sttService := stt.New(sttClient)
-	ttsService := tts.New(ttsClient)
+	sttService := stt.New(sttClient, i18n.Default, alternativeLanguages())
+	ttsService := tts.New(ttsClient, voices())
 	commandListener := rv.New()
-	commandsService := commands.New(ttsService)
+	session := dialog.New(ttsService, commandListener, sttService, 10*time.Second)
+
+	phas := start(ctx, ttsService, false)
+
+	for {
+		log.Println("waiting for wakewords")
+		word, cx := session.WaitForWakeWord(ctx, wwListener)
+		if word == "terminator" {
+			break
+		}
+		utterance, err := session.Record(cx)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		phas.userCommands <- &intents.Request{
+			Transcript: utterance.Text,
+			Source:     intents.SourceVoice,
+			WakeWord:   word,
+			Language:   utterance.Language,
+			Dialog:     session,
+			Latencies: map[string]time.Duration{
+				"recording": utterance.Recording,
+				"stt":       utterance.Recognition,
+			},
+		}
+	}
+
+	phas.stop(ctx)
+}
+
+// system holds the services shared by all the ways of running PHAS
+type system struct {
+	wait         chan struct{}
+	userCommands chan *intents.Request
+	tts          *tts.Service
+	schedule     *scheduler.Service
+	api          *api.Server
+	audit        *audit.Log
+	lights       *lights.Service
+}
+
+// The interactions audit log is rotated at about 10MB, and 5 rotated files are kept
+const (
+	auditMaxSize  = 10 << 20
+	auditMaxFiles = 5
+)
+
+// configFile returns the path of the PHAS configuration file
+func configFile() string {
+	if path := os.Getenv("PHAS_CONFIG"); path != "" {
+		return path
+	}
+	return "phas.json"
+}
+
+// start creates the PHAS services and starts handling the user commands.
+// In text mode, it skips the services which need devices or the network: the HTTP API, the sentry mode,
+// the SMS modem, and the lights.
+func start(ctx context.Context, ttsService *tts.Service, text bool) *system {
+	wait := make(chan struct{})
+	userCommands := make(chan *intents.Request, 10)
+	auditLog, err := audit.Open(auditFile(), auditMaxSize, auditMaxFiles)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	registry := intents.NewRegistry()
+	commandsService := commands.New(ttsService, auditLog, registry)
 
 	smsCOMPort := os.Getenv("PHAS_SMS_COM_PORT")
-	if smsCOMPort == "" {
+	if smsCOMPort == "" || text {
 		smsCOMPort = "stub"
 	}
 	smsService, err := sms.New(smsCOMPort, 115200)
@@ -58,8 +162,34 @@ func main() {
 		log.Fatalln(err)
 	}
 
-	hueAddr, hueUser := os.Getenv("PHAS_HUE_ADDR"), os.Getenv("PHAS_HUE_USER")
-	lightsService := hue.New(hueAddr, hueUser)
+	configPath := configFile()
+	cfg, err := config.Load(configPath)
+	if os.IsNotExist(err) {
+		log.Printf("configuration file %s not found\n", configPath)
+		cfg = &config.Config{}
+	} else if err != nil {
+		log.Fatalln(err)
+	}
+
+	hueService := hue.New("", "")
+	var backends []lights.Backend
+	if !text {
+		hueAddr, hueUser := os.Getenv("PHAS_HUE_ADDR"), os.Getenv("PHAS_HUE_USER")
+		if hueAddr == "" && hueUser == "" && cfg.Hue != nil {
+			hueAddr, hueUser = cfg.Hue.Address, cfg.Hue.User
+		}
+		hueService = hue.New(hueAddr, hueUser)
+		if hueService.Configured() {
+			backends = append(backends, hueService)
+		}
+		if len(cfg.WLED) > 0 {
+			backends = append(backends, wled.New(cfg.WLED))
+		}
+		if cfg.MQTT != nil {
+			backends = append(backends, mqtt.New(*cfg.MQTT))
+		}
+	}
+	lightsService := lights.New(backends...)
 
 	cameraID := 0
 	cam := os.Getenv("PHAS_SENTRY_CAM")
@@ -76,40 +206,115 @@ func main() {
 
 	sentryLightGroup := os.Getenv("PHAS_SENTRY_LIGHT_GROUP")
 	sentryPhoneNumber := os.Getenv("PHAS_SENTRY_PHONE")
+	sentryAlarm := os.Getenv("PHAS_SENTRY_ALARM")
 
-	sentryService := sentry.New(cameraID, 3000, wait, func(sinceLastAlarm float64) {
-		if sinceLastAlarm > 20 {
-			ttsService.Speak(ctx, "Intruder detected! Sound the alarm!")
+	var sentryService *sentry.Service
+	if !text {
+		sentryService = sentry.New(cameraID, 3000, wait, func(armed context.Context, sinceLastAlarm float64) {
+			if sinceLastAlarm > 20 {
+				alert := i18n.Sprintf(ctx, "Intruder detected! Sound the alarm!")
+				ttsService.Speak(ctx, alert)
 
-			err := smsService.SendSMS(sentryPhoneNumber, "Intruder detected! Sound the alarm!")
-			if err != nil {
-				log.Println(err)
-			}
+				err := smsService.SendSMS(sentryPhoneNumber, alert)
+				if err != nil {
+					log.Println(err)
+				}
 
-			err = lightsService.Alarm(sentryLightGroup)
-			if err != nil {
-				log.Println(err)
+				// The alarm stops early when the sentry mode is turned off
+				err = lightsService.Alarm(armed, sentryLightGroup, sentryAlarm)
+				if err != nil {
+					log.Println(err)
+				}
 			}
-		}
+		})
+	}
+
+	if threshold, err := strconv.ParseFloat(os.Getenv("PHAS_INTENT_ACCEPT_SCORE"), 64); err == nil {
+		intents.AcceptThreshold = threshold
+	}
+	if threshold, err := strconv.ParseFloat(os.Getenv("PHAS_INTENT_CONFIRM_SCORE"), 64); err == nil {
+		intents.ConfirmThreshold = threshold
+	}
+	timersFile := os.Getenv("PHAS_TIMERS_FILE")
+	if timersFile == "" {
+		timersFile = "phas-timers.json"
+	}
+	timersService, err := timers.New(timersFile, ttsService, smsService, os.Getenv("PHAS_TIMERS_PHONE"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	registerActions(sentryService, lightsService, hueService)
+	registerTimerActions(timersService)
+	pluginManager := plugins.NewManager()
+	intents.RegisterAction(plugins.ActionName, pluginManager.Action)
+	registerChecks(sentryService)
+	routineRunner := routines.NewRunner()
+	// "stop" also ends what runs in the background
+	commandsService.OnStop = func() {
+		routineRunner.Cancel("")
+		lightsService.StopAlarm()
+	}
+	schedule := scheduler.New(func(req *intents.Request) {
+		userCommands <- req
 	})
+	if err := loadIntents(registry, cfg, routineRunner, schedule, pluginManager, lightsService); err != nil {
+		log.Fatalln(err)
+	}
 
-	registerIntents(sentryService)
+	// Reload the intents without restarting the wakeword loop
+	go config.Watch(ctx, configPath, 5*time.Second, func(cfg *config.Config) {
+		if err := loadIntents(registry, cfg, routineRunner, schedule, pluginManager, lightsService); err != nil {
+			log.Printf("keeping the current intents and alarms: %v\n", err)
+		}
+	})
 
 	// Handle sends a close message when done
 	go commandsService.Handle(wait, userCommands)
+	schedule.Start(ctx)
 
-	for {
-		log.Println("waiting for wakewords")
-		word, cx := wwListener.Listen(ctx)
-		if word == "terminator" {
-			break
+	var apiServer *api.Server
+	if !text {
+		apiAddr := os.Getenv("PHAS_API_ADDR")
+		if apiAddr == "" {
+			apiAddr = "localhost:42081"
 		}
-		userCommands <- sttService.Process(cx, commandListener.Listen())
+		apiServer = api.New(apiAddr, func(req *intents.Request) {
+			userCommands <- req
+		}, registry)
+		go func() {
+			if err := apiServer.ListenAndServe(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+
+	return &system{
+		wait:         wait,
+		userCommands: userCommands,
+		tts:          ttsService,
+		schedule:     schedule,
+		api:          apiServer,
+		audit:        auditLog,
+		lights:       lightsService,
 	}
+}
 
-	//Clean shutdown of the system
-	close(userCommands)
-	<-wait
-	ttsService.Speak(ctx, "I'll be back!")
+//Clean shutdown of the system
+func (s *system) stop(ctx context.Context) {
+	s.schedule.Stop()
+	if s.api != nil {
+		if err := s.api.Shutdown(ctx); err != nil {
+			log.Println(err)
+		}
+	}
+	close(s.userCommands)
+	<-s.wait
+	// Put the lights back in their state from before the alarm
+	s.lights.StopAlarm()
+	if err := s.audit.Close(); err != nil {
+		log.Println(err)
+	}
+	s.tts.Speak(ctx, "I'll be back!")
 	log.Println("got command: exit")
 }
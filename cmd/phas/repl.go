@@ -0,0 +1,85 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dlsniper/phas/commands"
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/dialog"
+	"github.com/dlsniper/phas/tts"
+)
+
+// repl runs PHAS with the commands typed in the terminal, without the wakeword, microphone, GCP services, or devices
+func repl(ctx context.Context) {
+	ttsService := tts.NewConsole(os.Stdout)
+	console := dialog.NewConsole(ttsService, os.Stdin, os.Stdout)
+
+	phas := start(ctx, ttsService, true)
+	// "stop" interrupts the command that is running, even while it waits, like the voice commands
+	var mu sync.Mutex
+	stopped := false
+	console.Interrupt = func(line string) bool {
+		if !commands.IsStop(line) {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			phas.userCommands <- &intents.Request{Transcript: line, Source: intents.SourceText}
+		}
+		return true
+	}
+
+	for {
+		line, err := console.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Println(err)
+			break
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line == "exit" || line == "quit" {
+			break
+		}
+
+		req := &intents.Request{
+			Transcript: line,
+			Source:     intents.SourceText,
+			Dialog:     console,
+			Done:       make(chan struct{}),
+		}
+		phas.userCommands <- req
+		// Follow-up questions read the next lines, so wait for the command to finish
+		<-req.Done
+	}
+
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+	phas.stop(ctx)
+}
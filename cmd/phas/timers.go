@@ -0,0 +1,187 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/timers"
+	"github.com/dlsniper/phas/tts"
+)
+
+var timerIntents = []intents.Definition{
+	{
+		Command:      "set a timer for {duration}",
+		Category:     "timers",
+		Alternatives: []string{"start a timer for {duration}", "set a {duration} timer"},
+		Actions:      []intents.ActionDefinition{{Name: "timer_set"}},
+	},
+	{
+		Command:      "remind me at {time} to {what}",
+		Category:     "timers",
+		Alternatives: []string{"remind me in {duration} to {what}", "remind me to {what} at {time}", "remind me to {what} in {duration}"},
+		Actions:      []intents.ActionDefinition{{Name: "reminder_set"}},
+	},
+	{
+		Command:      "wake me up at {time}",
+		Category:     "timers",
+		Alternatives: []string{"set an alarm for {time}", "set an alarm at {time}"},
+		Actions:      []intents.ActionDefinition{{Name: "alarm_set"}},
+	},
+	{
+		Command:      "list my timers",
+		Category:     "timers",
+		Alternatives: []string{"what timers do i have", "list my reminders", "list my alarms"},
+		Actions:      []intents.ActionDefinition{{Name: "timer_list"}},
+	},
+	{
+		Command:  "cancel the timer",
+		Category: "timers",
+		Alternatives: []string{
+			"cancel my timer",
+			"stop the timer",
+		},
+		Actions: []intents.ActionDefinition{{Name: "timer_cancel", Params: intents.Params{"kind": "timer"}}},
+	},
+	{
+		Command:  "cancel the reminder",
+		Category: "timers",
+		Alternatives: []string{
+			"cancel my reminder",
+		},
+		Actions: []intents.ActionDefinition{{Name: "timer_cancel", Params: intents.Params{"kind": "reminder"}}},
+	},
+	{
+		Command:  "cancel the alarm",
+		Category: "timers",
+		Alternatives: []string{
+			"cancel my alarm",
+		},
+		Actions: []intents.ActionDefinition{{Name: "timer_cancel", Params: intents.Params{"kind": "alarm"}}},
+	},
+	{
+		Command:  "cancel all timers",
+		Category: "timers",
+		Alternatives: []string{
+			"cancel all my timers",
+			"cancel all reminders",
+			"cancel all alarms",
+		},
+		Actions: []intents.ActionDefinition{{Name: "timer_cancel", Params: intents.Params{"all": "true"}}},
+	},
+}
+
+func registerTimerActions(t *timers.Service) {
+	intents.RegisterAction("timer_set", func(intents.Params) (intents.Action, error) {
+		return func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+			d, err := req.Duration("duration")
+			if err != nil {
+				return err
+			}
+			if _, err := t.Add(timers.KindTimer, req.Slot("duration"), time.Now().Add(d), req.Language); err != nil {
+				return err
+			}
+			ttsService.Speak(ctx, i18n.Sprintf(ctx, "Timer set for %s.", req.Slot("duration")))
+			return nil
+		}, nil
+	})
+
+	intents.RegisterAction("reminder_set", func(intents.Params) (intents.Action, error) {
+		return func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+			var fireAt time.Time
+			if req.Slot("time") != "" {
+				var err error
+				fireAt, err = req.Clock("time", time.Now())
+				if err != nil {
+					return err
+				}
+			} else {
+				d, err := req.Duration("duration")
+				if err != nil {
+					return err
+				}
+				fireAt = time.Now().Add(d)
+			}
+
+			if _, err := t.Add(timers.KindReminder, req.Slot("what"), fireAt, req.Language); err != nil {
+				return err
+			}
+			ttsService.Speak(ctx, i18n.Sprintf(ctx, "OK, I will remind you at %s to %s.", fireAt.Format("15:04"), req.Slot("what")))
+			return nil
+		}, nil
+	})
+
+	intents.RegisterAction("alarm_set", func(intents.Params) (intents.Action, error) {
+		return func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+			fireAt, err := req.Clock("time", time.Now())
+			if err != nil {
+				return err
+			}
+			if _, err := t.Add(timers.KindAlarm, "", fireAt, req.Language); err != nil {
+				return err
+			}
+			ttsService.Speak(ctx, i18n.Sprintf(ctx, "Alarm set for %s.", fireAt.Format("15:04")))
+			return nil
+		}, nil
+	})
+
+	intents.RegisterAction("timer_list", func(intents.Params) (intents.Action, error) {
+		return func(ctx context.Context, _ *intents.Request, ttsService *tts.Service) error {
+			list := t.List()
+			if len(list) == 0 {
+				ttsService.Speak(ctx, i18n.Sprintf(ctx, "You have no timers."))
+				return nil
+			}
+
+			var descriptions []string
+			for _, timer := range list {
+				descriptions = append(descriptions, timer.Describe(ctx))
+			}
+			ttsService.Speak(ctx, i18n.Sprintf(ctx, "You have %d: %s.", len(list), strings.Join(descriptions, ", ")))
+			return nil
+		}, nil
+	})
+
+	intents.RegisterAction("timer_cancel", func(params intents.Params) (intents.Action, error) {
+		kind := timers.Kind(params["kind"])
+		switch kind {
+		case "", timers.KindTimer, timers.KindReminder, timers.KindAlarm:
+		default:
+			return nil, fmt.Errorf("unknown timer kind %q", kind)
+		}
+		all := params["all"] == "true"
+
+		return func(ctx context.Context, _ *intents.Request, ttsService *tts.Service) error {
+			cancelled, err := t.Cancel(kind, all)
+			if err != nil {
+				return err
+			}
+			switch len(cancelled) {
+			case 0:
+				ttsService.Speak(ctx, i18n.Sprintf(ctx, "There is nothing to cancel."))
+			case 1:
+				ttsService.Speak(ctx, i18n.Sprintf(ctx, "I cancelled %s.", cancelled[0].Describe(ctx)))
+			default:
+				ttsService.Speak(ctx, i18n.Sprintf(ctx, "I cancelled %d timers.", len(cancelled)))
+			}
+			return nil
+		}, nil
+	})
+}
@@ -17,34 +17,304 @@ package commands
 import (
 	"context"
 	"log"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/dlsniper/phas/audit"
 	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/i18n"
 	"github.com/dlsniper/phas/tts"
 )
 
 //Service handles commands from the user
 type Service struct {
-	tts *tts.Service
+	tts      *tts.Service
+	audit    *audit.Log
+	registry *intents.Registry
+	context  conversation
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+
+	//OnStop, when set, is called by the "stop" commands, to also stop what runs in the background, such as an alarm or a routine
+	OnStop func()
 }
 
-//Handle processes the incoming command and transforms it into a response
-func (s *Service) Handle(wait chan struct{}, userCommands <-chan string) {
-	for userCommand := range userCommands {
-		//goland:noinspection GoShadowedVar
-		userCommand := strings.ToLower(userCommand)
-		log.Printf("got command: %q\n", userCommand)
-		ctx := context.Background()
-		intent := intents.ConvertToIntent(ctx, userCommand)
-		intent.Execute(ctx, s.tts)
+// queueSize is how many commands can wait while another command runs
+const queueSize = 100
+
+//Handle processes the incoming command and transforms it into a response.
+//The commands run one at a time, and a "stop" or "cancel" command interrupts the one that is running.
+func (s *Service) Handle(wait chan struct{}, userCommands <-chan *intents.Request) {
+	queue := make(chan *intents.Request, queueSize)
+	done := make(chan struct{})
+	go func() {
+		for req := range queue {
+			s.run(req)
+		}
+		close(done)
+	}()
+
+	for req := range userCommands {
+		if IsStop(req.Transcript) {
+			s.stop(req)
+			continue
+		}
+		queue <- req
 	}
+	close(queue)
+	<-done
 
 	close(wait)
 }
 
-//New creates a new Service to handle the user commands
-func New(ttsService *tts.Service) *Service {
+// run handles the command under a context which is cancelled when the user stops it
+func (s *Service) run(req *intents.Request) {
+	log.Printf("got %s command: %q\n", req.Source, req.Transcript)
+	received := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	if req.Language != "" {
+		ctx = i18n.WithLanguage(ctx, req.Language)
+	}
+
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	s.handle(ctx, req)
+
+	s.mu.Lock()
+	s.cancel = nil
+	s.mu.Unlock()
+	cancel()
+
+	s.record(received, req)
+	if req.Done != nil {
+		close(req.Done)
+	}
+}
+
+// stop interrupts the running command and any speech in progress
+func (s *Service) stop(req *intents.Request) {
+	log.Printf("got %s command: %q\n", req.Source, req.Transcript)
+	received := time.Now()
+
+	s.mu.Lock()
+	if s.cancel != nil {
+		log.Println("stopping the running command")
+		s.cancel()
+	}
+	s.mu.Unlock()
+	s.tts.Stop()
+	if s.OnStop != nil {
+		// It can wait for the lights to be restored, which shouldn't hold the next commands
+		go s.OnStop()
+	}
+
+	req.Results = append(req.Results, &intents.Result{Intent: "stop", Phrase: req.Transcript, Score: 1})
+	s.record(received, req)
+	if req.Done != nil {
+		close(req.Done)
+	}
+}
+
+var stopCommands = map[string]bool{
+	"stop":        true,
+	"stop it":     true,
+	"stop that":   true,
+	"cancel":      true,
+	"cancel it":   true,
+	"cancel that": true,
+	"never mind":  true,
+	"nevermind":   true,
+	"be quiet":    true,
+	"shut up":     true,
+	"oprește":     true,
+	"anulează":    true,
+	"taci":        true,
+}
+
+//IsStop tells if the command asks to stop the running command
+func IsStop(command string) bool {
+	return stopCommands[strings.Trim(strings.ToLower(command), " .!")]
+}
+
+// conjunctions split a compound command, e.g. "turn off the lights and turn on the sentry mode"
+var conjunctions = regexp.MustCompile(`\s*(?:,\s*)?\b(?:and then|and|then)\b\s*`)
+
+// splitCommand returns the parts of a compound command
+func splitCommand(command string) []string {
+	var parts []string
+	for _, part := range conjunctions.Split(command, -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func (s *Service) handle(ctx context.Context, req *intents.Request) {
+	start := time.Now()
+	userCommand := strings.ToLower(req.Transcript)
+
+	if isRepeat(userCommand) && s.context.fresh(start) {
+		match := *s.context.match
+		match.Slots = intents.Slots{}
+		for name, value := range s.context.match.Slots {
+			match.Slots[name] = value
+		}
+		req.Measure("matching", time.Since(start))
+		start = time.Now()
+		req.Results = append(req.Results, s.execute(ctx, req, &match))
+		req.Measure("execution", time.Since(start))
+		return
+	}
+
+	match := s.registry.Match(ctx, userCommand)
+	s.context.resolve(start, userCommand, match)
+	if match.Score < 1 {
+		if parts, matches := s.compound(ctx, userCommand); matches != nil {
+			req.Measure("matching", time.Since(start))
+			start = time.Now()
+			s.executeAll(ctx, req, parts, matches)
+			req.Measure("execution", time.Since(start))
+			return
+		}
+	}
+	req.Measure("matching", time.Since(start))
+
+	start = time.Now()
+	req.Results = append(req.Results, s.execute(ctx, req, match))
+	req.Measure("execution", time.Since(start))
+}
+
+// record writes the interaction to the audit log
+func (s *Service) record(received time.Time, req *intents.Request) {
+	if s.audit == nil {
+		return
+	}
+
+	entry := audit.Entry{
+		Time:       received,
+		Source:     string(req.Source),
+		WakeWord:   req.WakeWord,
+		Language:   req.Language,
+		Transcript: req.Transcript,
+		Latencies:  map[string]int64{},
+	}
+	for stage, d := range req.Latencies {
+		entry.Latencies[stage] = d.Milliseconds()
+	}
+	for _, result := range req.Results {
+		i := audit.Intent{
+			Intent:  result.Intent,
+			Phrase:  result.Phrase,
+			Score:   result.Score,
+			Slots:   result.Slots,
+			Aborted: result.Aborted,
+			Skipped: result.Skipped,
+		}
+		for _, step := range result.Steps {
+			a := audit.Action{Name: step.Name, Attempts: step.Attempts}
+			if step.Err != nil {
+				a.Error = step.Err.Error()
+			}
+			i.Actions = append(i.Actions, a)
+		}
+		entry.Intents = append(entry.Intents, i)
+	}
+
+	if err := s.audit.Write(entry); err != nil {
+		log.Printf("failed to write the audit log: %v\n", err)
+	}
+}
+
+// compound matches each part of a compound command, or returns nil matches if the command is not one
+func (s *Service) compound(ctx context.Context, command string) ([]string, []*intents.Match) {
+	parts := splitCommand(command)
+	if len(parts) < 2 {
+		return nil, nil
+	}
+
+	var matches []*intents.Match
+	for _, part := range parts {
+		match := s.registry.Match(ctx, part)
+		if match.Score < intents.ConfirmThreshold {
+			return nil, nil
+		}
+		matches = append(matches, match)
+	}
+	return parts, matches
+}
+
+func (s *Service) execute(ctx context.Context, req *intents.Request, match *intents.Match) *intents.Result {
+	log.Printf("matched %q with score %.2f\n", match.Phrase, match.Score)
+	req.Phrase = match.Phrase
+	req.Slots = match.Slots
+	if match.NeedsConfirmation() {
+		question := i18n.Sprintf(ctx, "Did you mean %s? Please answer yes or no.", match.Phrase)
+		if !req.Confirm(ctx, question) {
+			s.tts.Speak(ctx, i18n.Sprintf(ctx, "OK, I will not do it."))
+			return &intents.Result{
+				Intent:  match.Intent.Command,
+				Phrase:  match.Phrase,
+				Score:   match.Score,
+				Skipped: true,
+			}
+		}
+	}
+
+	result := match.Intent.Execute(ctx, req, s.tts)
+	result.Score = match.Score
+	if err := result.Err(); err != nil {
+		log.Println(err)
+	}
+
+	// Scheduled commands are not part of the conversation with the user
+	if req.Source != intents.SourceSchedule && match.Intent.Command != "" {
+		s.context.remember(time.Now(), match.Intent, match.Phrase, req.Slots)
+	}
+	return result
+}
+
+// executeAll runs the intents of a compound command in order and speaks a summary at the end
+func (s *Service) executeAll(ctx context.Context, req *intents.Request, parts []string, matches []*intents.Match) {
+	var done, failed []string
+	for idx, match := range matches {
+		if ctx.Err() != nil {
+			break
+		}
+		partReq := *req
+		partReq.Transcript = parts[idx]
+		result := s.execute(ctx, &partReq, match)
+		req.Results = append(req.Results, result)
+		switch {
+		case result.Skipped:
+		case result.Failed():
+			failed = append(failed, match.Phrase)
+		default:
+			done = append(done, match.Phrase)
+		}
+	}
+
+	summary := i18n.Sprintf(ctx, "I did %d of %d things.", len(done), len(matches))
+	if len(done) == len(matches) {
+		summary = i18n.Sprintf(ctx, "All %d things are done.", len(matches))
+	}
+	if len(failed) > 0 {
+		summary += " " + i18n.Sprintf(ctx, "These failed: %s.", strings.Join(failed, ", and "))
+	}
+	s.tts.Speak(ctx, summary)
+}
+
+//New creates a new Service to handle the user commands, by matching them against the Intents of the registry.
+//Each interaction is written to the audit log, when not nil.
+func New(ttsService *tts.Service, auditLog *audit.Log, registry *intents.Registry) *Service {
 	return &Service{
-		tts: ttsService,
+		tts:      ttsService,
+		audit:    auditLog,
+		registry: registry,
 	}
 }
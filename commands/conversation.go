@@ -0,0 +1,104 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package commands
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+)
+
+// contextExpiry is how long the previous command can be referred to
+const contextExpiry = 2 * time.Minute
+
+var pronouns = map[string]bool{
+	"it":    true,
+	"them":  true,
+	"they":  true,
+	"that":  true,
+	"those": true,
+	"these": true,
+}
+
+var repeats = map[string]bool{
+	"again":          true,
+	"do it again":    true,
+	"do that again":  true,
+	"repeat that":    true,
+	"one more time":  true,
+	"same thing":     true,
+	"the same again": true,
+}
+
+// conversation remembers the last intent and entities, so that follow-ups such as "turn them off" can refer to them
+type conversation struct {
+	at     time.Time
+	match  *intents.Match
+	entity intents.Slots
+}
+
+func (c *conversation) fresh(now time.Time) bool {
+	return c.match != nil && now.Sub(c.at) < contextExpiry
+}
+
+// remember stores the intent that ran, and its slots as the entities for the next commands
+func (c *conversation) remember(now time.Time, intent *intents.Intent, phrase string, slots intents.Slots) {
+	entity := intents.Slots{}
+	for name, value := range slots {
+		entity[name] = value
+	}
+
+	c.at = now
+	c.match = &intents.Match{Intent: intent, Phrase: phrase, Slots: entity, Score: 1}
+	if len(entity) > 0 {
+		c.entity = entity
+	}
+}
+
+// isRepeat tells if the command asks to run the previous intent again
+func isRepeat(command string) bool {
+	return repeats[strings.Trim(command, " .!")]
+}
+
+func hasPronoun(command string) bool {
+	for _, word := range strings.Fields(command) {
+		if pronouns[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve fills the slots the intent needs, but the command does not have, from the entities of the previous command
+func (c *conversation) resolve(now time.Time, command string, match *intents.Match) {
+	if !c.fresh(now) || !hasPronoun(command) {
+		return
+	}
+
+	for _, name := range match.Intent.SlotNames() {
+		if match.Slots[name] != "" {
+			continue
+		}
+		if value, ok := c.entity[name]; ok {
+			log.Printf("using %s %q from the previous command\n", name, value)
+			if match.Slots == nil {
+				match.Slots = intents.Slots{}
+			}
+			match.Slots[name] = value
+		}
+	}
+}
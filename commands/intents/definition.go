@@ -0,0 +1,153 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package intents
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dlsniper/phas/normalize"
+)
+
+//Params holds the parameters of an action as written in the intents definition
+type Params map[string]string
+
+//An ActionFactory creates an Action from its parameters
+type ActionFactory func(params Params) (Action, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]ActionFactory{}
+)
+
+//RegisterAction makes an action available by name to the intents definitions
+func RegisterAction(name string, factory ActionFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+//ActionNames returns the names of the registered actions
+func ActionNames() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//ActionDefinition names a registered action, its parameters, and what to do when it fails
+type ActionDefinition struct {
+	Name    string      `json:"name"`
+	Params  Params      `json:"params,omitempty"`
+	OnError ErrorPolicy `json:"on_error,omitempty"`
+	Retries int         `json:"retries,omitempty"`
+	Backoff string      `json:"backoff,omitempty"`
+}
+
+//Definition describes an Intent in a declarative way, e.g. in the configuration file
+type Definition struct {
+	Command      string             `json:"command"`
+	Alternatives []string           `json:"alternatives,omitempty"`
+	Actions      []ActionDefinition `json:"actions"`
+	Prompts      map[string]string  `json:"prompts,omitempty"`
+	Language     string             `json:"language,omitempty"`
+	Category     string             `json:"category,omitempty"`
+	Priority     int                `json:"priority,omitempty"`
+}
+
+//Build validates the Definition and creates the Intent described by it
+func (d Definition) Build() (*Intent, error) {
+	if strings.TrimSpace(d.Command) == "" {
+		return nil, fmt.Errorf("intent without a command")
+	}
+	if len(d.Actions) == 0 {
+		return nil, fmt.Errorf("intent %q has no actions", d.Command)
+	}
+
+	for _, phrase := range append([]string{d.Command}, d.Alternatives...) {
+		if strings.Count(phrase, "{") != strings.Count(phrase, "}") ||
+			strings.Count(phrase, "{") != len(placeholder.FindAllString(phrase, -1)) {
+			return nil, fmt.Errorf("intent %q has an invalid placeholder in %q", d.Command, phrase)
+		}
+	}
+
+	intent := &Intent{
+		Command:      d.Command,
+		Alternatives: d.Alternatives,
+		Prompts:      d.Prompts,
+		Language:     d.Language,
+		Category:     strings.ToLower(strings.TrimSpace(d.Category)),
+		Priority:     d.Priority,
+	}
+
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	for idx, def := range d.Actions {
+		factory, ok := factories[def.Name]
+		if !ok {
+			return nil, fmt.Errorf("intent %q uses unknown action %q at position %d", d.Command, def.Name, idx)
+		}
+		action, err := factory(def.Params)
+		if err != nil {
+			return nil, fmt.Errorf("intent %q action %q: %w", d.Command, def.Name, err)
+		}
+
+		step := Step{
+			Name:    def.Name,
+			Action:  action,
+			OnError: def.OnError,
+			Retries: def.Retries,
+		}
+		switch step.OnError {
+		case "":
+			step.OnError = Abort
+		case Abort, Continue, Retry, Ask:
+		default:
+			return nil, fmt.Errorf("intent %q action %q has an unknown error policy %q", d.Command, def.Name, def.OnError)
+		}
+		if def.Backoff != "" {
+			step.Backoff, err = normalize.Duration(def.Backoff)
+			if err != nil {
+				return nil, fmt.Errorf("intent %q action %q has an invalid backoff: %w", d.Command, def.Name, err)
+			}
+		}
+		intent.Steps = append(intent.Steps, step)
+	}
+
+	return intent, nil
+}
+
+//BuildAll creates the Intents for all the given Definitions and checks that no phrase is used twice in a language
+func BuildAll(defs []Definition) ([]*Intent, error) {
+	var res []*Intent
+	for _, def := range defs {
+		intent, err := def.Build()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, intent)
+	}
+
+	if err := CheckPhrases(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,231 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package intents
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/tts"
+)
+
+//ErrorPolicy tells Execute what to do when an action fails
+type ErrorPolicy string
+
+//The available error policies
+const (
+	//Abort stops the Intent at the failing action. This is the default.
+	Abort ErrorPolicy = "abort"
+	//Continue runs the remaining actions of the Intent
+	Continue ErrorPolicy = "continue"
+	//Retry runs the action again, waiting longer after each attempt
+	Retry ErrorPolicy = "retry"
+	//Ask lets the user decide to retry, continue, or abort
+	Ask ErrorPolicy = "ask"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = time.Second
+)
+
+//A Step is an Action of an Intent together with what to do when it fails
+type Step struct {
+	Name    string
+	Action  Action
+	OnError ErrorPolicy
+	//Retries is how many times the action runs again with the Retry policy
+	Retries int
+	//Backoff is the wait before the first retry, doubled after every attempt
+	Backoff time.Duration
+}
+
+func (s Step) retries() int {
+	if s.Retries > 0 {
+		return s.Retries
+	}
+	return defaultRetries
+}
+
+func (s Step) backoff() time.Duration {
+	if s.Backoff > 0 {
+		return s.Backoff
+	}
+	return defaultBackoff
+}
+
+//StepResult is the outcome of running a Step
+type StepResult struct {
+	Name     string
+	Attempts int
+	Err      error
+}
+
+//Result is the outcome of running all the Steps of an Intent
+type Result struct {
+	Intent string
+	Phrase string
+	Slots  Slots
+	Score  float64
+	Steps  []StepResult
+	//Aborted tells if some Steps did not run because of a failure, or because the command was stopped
+	Aborted bool
+	//Skipped tells if the user did not confirm the Intent, so none of its Steps ran
+	Skipped bool
+}
+
+//Failed tells if any of the Steps failed
+func (r *Result) Failed() bool {
+	return r.Err() != nil
+}
+
+//Err combines the errors of all the failed Steps, or returns nil if all of them succeeded
+func (r *Result) Err() error {
+	var msgs []string
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", step.Name, step.Err))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("intent %q failed at %s", r.Intent, strings.Join(msgs, "; "))
+}
+
+// maxExplanation keeps the spoken errors short
+const maxExplanation = 80
+
+func explain(err error) string {
+	// The length is counted in runes, so that the translated errors are not cut in the middle of a character
+	msg := []rune(err.Error())
+	if len(msg) > maxExplanation {
+		return string(msg[:maxExplanation]) + "..."
+	}
+	return string(msg)
+}
+
+// sleep waits for the given duration unless the context is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// run executes the Step according to its error policy and tells if the Intent can continue
+func (s Step) run(ctx context.Context, req *Request, tts *tts.Service) (StepResult, bool) {
+	res := StepResult{Name: s.Name}
+	backoff := s.backoff()
+	for {
+		res.Attempts++
+		res.Err = s.Action(ctx, req, tts)
+		if res.Err == nil {
+			return res, true
+		}
+		log.Printf("error %v while executing %q, attempt %d\n", res.Err, s.Name, res.Attempts)
+
+		switch s.OnError {
+		case Continue:
+			tts.Speak(ctx, i18n.Sprintf(ctx, "Sorry, %s failed: %s. I will continue.", s.Name, explain(res.Err)))
+			return res, true
+		case Retry:
+			if res.Attempts > s.retries() || !sleep(ctx, backoff) {
+				tts.Speak(ctx, i18n.Sprintf(ctx, "Sorry, %s failed: %s.", s.Name, explain(res.Err)))
+				return res, false
+			}
+			backoff *= 2
+		case Ask:
+			if req.Dialog == nil {
+				tts.Speak(ctx, i18n.Sprintf(ctx, "Sorry, %s failed: %s.", s.Name, explain(res.Err)))
+				return res, false
+			}
+			if req.Confirm(ctx, i18n.Sprintf(ctx, "Sorry, %s failed: %s. Should I try again?", s.Name, explain(res.Err))) {
+				continue
+			}
+			return res, req.Confirm(ctx, i18n.Sprintf(ctx, "Should I continue with the rest of the command?"))
+		default:
+			tts.Speak(ctx, i18n.Sprintf(ctx, "Sorry, %s failed: %s.", s.Name, explain(res.Err)))
+			return res, false
+		}
+	}
+}
+
+// fillSlots asks the user for the slot values the command did not contain
+func (i *Intent) fillSlots(ctx context.Context, req *Request) error {
+	var names []string
+	for name := range i.Prompts {
+		if req.Slot(name) == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		answer, err := req.Ask(ctx, i.Prompts[name])
+		if err != nil {
+			return fmt.Errorf("could not get the %s: %w", name, err)
+		}
+		if req.Slots == nil {
+			req.Slots = Slots{}
+		}
+		req.Slots[name] = strings.ToLower(answer)
+	}
+
+	return nil
+}
+
+//Execute runs the Steps of the current Intent and reports the failures to the user
+func (i *Intent) Execute(ctx context.Context, req *Request, tts *tts.Service) *Result {
+	res := &Result{
+		Intent: i.Command,
+		Phrase: req.Phrase,
+	}
+	err := i.fillSlots(ctx, req)
+	res.Slots = req.Slots
+	if err != nil {
+		tts.Speak(ctx, i18n.Sprintf(ctx, "Sorry, I did not get an answer."))
+		res.Steps = append(res.Steps, StepResult{Name: "prompt", Err: err})
+		res.Aborted = true
+		return res
+	}
+
+	for idx, step := range i.Steps {
+		if ctx.Err() != nil {
+			res.Aborted = true
+			break
+		}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step %d", idx+1)
+		}
+		stepRes, ok := step.run(ctx, req, tts)
+		res.Steps = append(res.Steps, stepRes)
+		if !ok {
+			res.Aborted = idx < len(i.Steps)-1
+			break
+		}
+	}
+
+	return res
+}
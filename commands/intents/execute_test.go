@@ -0,0 +1,38 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package intents
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExplain(t *testing.T) {
+	short := "the bridge is not available"
+	if got := explain(errors.New(short)); got != short {
+		t.Errorf("explain(%q) = %q", short, got)
+	}
+
+	long := strings.Repeat("ă", maxExplanation+10)
+	got := explain(errors.New(long))
+	if !utf8.ValidString(got) {
+		t.Errorf("explain returned invalid UTF-8: %q", got)
+	}
+	if want := strings.Repeat("ă", maxExplanation) + "..."; got != want {
+		t.Errorf("explain(%q) = %q, want %q", long, got, want)
+	}
+}
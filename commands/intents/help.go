@@ -0,0 +1,138 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+
+package intents
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/tts"
+)
+
+// otherCategory holds the Intents without a category
+const otherCategory = "other"
+
+//HelpIntents creates the Intents which tell the user what they can say, grouped by category, one for each language.
+//They read the Registry each time they run, so they know about the Intents added later.
+func (r *Registry) HelpIntents() []*Intent {
+	steps := []Step{
+		{
+			Name: "help",
+			Action: func(ctx context.Context, req *Request, tts *tts.Service) error {
+				return help(ctx, req, tts, r.categories(ctx))
+			},
+		},
+	}
+
+	return []*Intent{
+		{
+			Command: "what can i say",
+			Alternatives: []string{
+				"what can you do",
+				"what commands do you know",
+				"help",
+				"help me",
+				"what can i say about {category}",
+				"help with {category}",
+			},
+			Category: "help",
+			Steps:    steps,
+		},
+		{
+			Command:      "ce pot să spun",
+			Alternatives: []string{"ajutor", "ce poți să faci"},
+			Language:     "ro-RO",
+			Category:     "help",
+			Steps:        steps,
+		},
+	}
+}
+
+func help(ctx context.Context, req *Request, tts *tts.Service, groups map[string][]string) error {
+	var names []string
+	count := 0
+	for name, phrases := range groups {
+		names = append(names, name)
+		count += len(phrases)
+	}
+	sort.Strings(names)
+
+	if wanted := strings.ToLower(req.Slot("category")); wanted != "" {
+		name := closestCategory(wanted, names)
+		if name == "" {
+			tts.Speak(ctx, i18n.Sprintf(ctx, "I don't know any commands about %s. I know about %s.", wanted, Join(ctx, names)))
+			return nil
+		}
+		tts.Speak(ctx, describeCategory(ctx, name, groups[name]))
+		return nil
+	}
+
+	tts.Speak(ctx, i18n.Sprintf(ctx, "I know %d commands about %s.", count, Join(ctx, names)))
+	if !req.Confirm(ctx, i18n.Sprintf(ctx, "Do you want to hear them?")) {
+		return nil
+	}
+	for _, name := range names {
+		tts.Speak(ctx, describeCategory(ctx, name, groups[name]))
+	}
+	return nil
+}
+
+// categories returns the phrases of the registered Intents in the language of the context, grouped by category
+func (r *Registry) categories(ctx context.Context) map[string][]string {
+	language := i18n.Language(ctx)
+	res := map[string][]string{}
+	for _, intent := range r.List() {
+		if intent.Command == "" || !i18n.Same(intent.Language, language) {
+			continue
+		}
+		name := intent.Category
+		if name == "" {
+			name = otherCategory
+		}
+		res[name] = append(res[name], spokenPhrase(intent.Command))
+	}
+	return res
+}
+
+// closestCategory returns the category the user asked about, or an empty string when there is none like it
+func closestCategory(wanted string, names []string) string {
+	best, bestScore := "", tokenThreshold
+	for _, name := range names {
+		if score := similarity(wanted, name); score >= bestScore {
+			best, bestScore = name, score
+		}
+	}
+	return best
+}
+
+// spokenPhrase replaces the placeholders with their names, e.g. "set a timer for {duration}" becomes "set a timer for duration"
+func spokenPhrase(phrase string) string {
+	return placeholder.ReplaceAllString(phrase, "$1")
+}
+
+func describeCategory(ctx context.Context, name string, phrases []string) string {
+	return i18n.Sprintf(ctx, "For %s, you can say: %s.", name, strings.Join(phrases, "; "))
+}
+
+//Join lists the items in a sentence, in the language of the context, e.g. "lights, timers, and other"
+func Join(ctx context.Context, items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return i18n.Sprintf(ctx, "%s, and %s", strings.Join(items[:len(items)-1], ", "), items[len(items)-1])
+}
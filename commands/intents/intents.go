@@ -17,75 +17,168 @@ package intents
 import (
 	"context"
 	"log"
+	"regexp"
+	"strings"
 
+	"github.com/dlsniper/phas/i18n"
 	"github.com/dlsniper/phas/tts"
 )
 
 //An Action runs whenever a user command matches with an Intent
-type Action func(context.Context, *tts.Service) error
+type Action func(context.Context, *Request, *tts.Service) error
 
-//An Intent contains of a command, alternative ways to give the command, and a series of actions that must run
+//An Intent contains of a command, alternative ways to give the command, and a series of steps that must run
 type Intent struct {
 	Command      string
 	Alternatives []string
-	Actions      []Action
+	Steps        []Step
+	//Prompts holds the questions asked for the slots missing from the command
+	Prompts map[string]string
+	//Language limits the Intent to the commands in a language, such as "ro-RO". When empty, any language matches.
+	Language string
+	//Category groups related Intents, such as "lights" or "timers", e.g. when telling the user what they can say
+	Category string
+	//Priority decides which Intent wins when several match a command equally well. Higher wins.
+	Priority int
 }
 
-// Weather in [city]
+//Slots holds the values captured by the placeholders of a phrase, e.g. "weather in {city}"
+type Slots map[string]string
 
-//Matches method checks if an Intent matches a given command
-func (i *Intent) Matches(_ context.Context, command string) bool {
-	// TODO Add placeholder support and return the found pairings as well
-	if command == i.Command {
-		return true
+//A Match is the result of matching a command against an Intent
+type Match struct {
+	Intent *Intent
+	Phrase string
+	Slots  Slots
+	Score  float64
+}
+
+//NeedsConfirmation tells if the user should confirm the Intent before it runs
+func (m *Match) NeedsConfirmation() bool {
+	return m.Intent != noMatchingIntent && m.Score < AcceptThreshold
+}
+
+var placeholder = regexp.MustCompile(`\{\s*(\w+)\s*\}`)
+
+// matchPhrase matches the command against a phrase which can contain placeholders
+func matchPhrase(phrase, command string) (Slots, bool) {
+	phrase = strings.Join(strings.Fields(phrase), " ")
+	command = strings.Join(strings.Fields(command), " ")
+
+	locs := placeholder.FindAllStringSubmatchIndex(phrase, -1)
+	if len(locs) == 0 {
+		return Slots{}, phrase == command
+	}
+
+	var names []string
+	pattern := "^"
+	last := 0
+	for _, loc := range locs {
+		pattern += regexp.QuoteMeta(phrase[last:loc[0]]) + "(.+?)"
+		names = append(names, phrase[loc[2]:loc[3]])
+		last = loc[1]
 	}
+	pattern += regexp.QuoteMeta(phrase[last:]) + "$"
 
-	for _, cmd := range i.Alternatives {
-		if cmd == command {
-			return true
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Printf("invalid phrase %q: %v\n", phrase, err)
+		return nil, false
+	}
+
+	found := re.FindStringSubmatch(command)
+	if found == nil {
+		return nil, false
+	}
+
+	slots := Slots{}
+	for idx, name := range names {
+		slots[name] = strings.TrimSpace(found[idx+1])
+	}
+	return slots, true
+}
+
+//Matches method checks if an Intent matches a given command and returns the values found for its placeholders
+func (i *Intent) Matches(_ context.Context, command string) (*Match, bool) {
+	for _, phrase := range i.phrases() {
+		if slots, ok := matchPhrase(phrase, command); ok {
+			return &Match{
+				Intent: i,
+				Phrase: phrase,
+				Slots:  slots,
+				Score:  1,
+			}, true
 		}
 	}
 
-	return false
+	return nil, false
 }
 
-//Execute runs the given actions for the current Intent
-func (i *Intent) Execute(ctx context.Context, tts *tts.Service) {
-	for idx, action := range i.Actions {
-		err := action(ctx, tts)
-		if err != nil {
-			log.Printf("error %v while executing the intent %q at action %d\n", err, i.Command, idx)
-			// TODO Handle errors that will allow the rest of the intent to run
-			// TODO Tell users about the errors encountered while running the intent and ask if the intent should continue the execution or retry
-			break
+//BestMatch returns the phrase of the Intent closest to the given command
+func (i *Intent) BestMatch(ctx context.Context, command string) *Match {
+	if match, ok := i.Matches(ctx, command); ok {
+		return match
+	}
+
+	best := &Match{Intent: i, Slots: Slots{}}
+	for _, phrase := range i.phrases() {
+		// Placeholders can only be filled by an exact match
+		if placeholder.MatchString(phrase) {
+			continue
+		}
+		if s := score(phrase, command); s > best.Score {
+			best.Phrase = phrase
+			best.Score = s
 		}
 	}
+
+	return best
 }
 
-var noMatchingIntent = &Intent{
-	Command: "",
-	Actions: []Action{
-		func(ctx context.Context, tts *tts.Service) error {
-			tts.Speak(ctx, "I could not understand your request. Please try again.")
-			return nil
-		},
-	},
+//Find returns the Intent from the list which matches the command exactly, or nil if there is none
+func Find(list []*Intent, command string) *Match {
+	for _, intent := range list {
+		if match, ok := intent.Matches(context.Background(), strings.ToLower(command)); ok {
+			return match
+		}
+	}
+	return nil
 }
 
-var intents []*Intent
+//SlotNames returns the names of all the placeholders and prompts of the Intent
+func (i *Intent) SlotNames() []string {
+	found := map[string]bool{}
+	var names []string
+	add := func(name string) {
+		if !found[name] {
+			found[name] = true
+			names = append(names, name)
+		}
+	}
 
-//ConvertToIntent handles converting the given command to an Intent
-func ConvertToIntent(ctx context.Context, command string) *Intent {
-	for _, intent := range intents {
-		if intent.Matches(ctx, command) {
-			return intent
+	for _, phrase := range i.phrases() {
+		for _, m := range placeholder.FindAllStringSubmatch(phrase, -1) {
+			add(m[1])
 		}
 	}
+	for name := range i.Prompts {
+		add(name)
+	}
+	return names
+}
 
-	return noMatchingIntent
+func (i *Intent) phrases() []string {
+	return append([]string{i.Command}, i.Alternatives...)
 }
 
-//RegisterIntent registers the available Intents
-func RegisterIntent(intent *Intent) {
-	intents = append(intents, intent)
+var noMatchingIntent = &Intent{
+	Command: "",
+	Steps: []Step{
+		{
+			Action: func(ctx context.Context, _ *Request, tts *tts.Service) error {
+				tts.Speak(ctx, i18n.Sprintf(ctx, "I could not understand your request. Please try again."))
+				return nil
+			},
+		},
+	},
 }
@@ -0,0 +1,97 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package intents
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchPhrase(t *testing.T) {
+	tests := []struct {
+		phrase  string
+		command string
+		slots   Slots
+		ok      bool
+	}{
+		{phrase: "turn on the lights", command: "turn on the lights", slots: Slots{}, ok: true},
+		{phrase: "turn on the lights", command: "turn  on the   lights", slots: Slots{}, ok: true},
+		{phrase: "turn on the lights", command: "turn on the kitchen lights", ok: false},
+		{phrase: "turn on the {room} lights", command: "turn on the kitchen lights", slots: Slots{"room": "kitchen"}, ok: true},
+		{phrase: "turn on the { room } lights", command: "turn on the living room lights", slots: Slots{"room": "living room"}, ok: true},
+		{phrase: "set the {room} lights to {level} percent", command: "set the kitchen lights to 40 percent", slots: Slots{"room": "kitchen", "level": "40"}, ok: true},
+		{phrase: "weather in {city}", command: "weather in new york", slots: Slots{"city": "new york"}, ok: true},
+		{phrase: "weather in {city}", command: "weather in", ok: false},
+		{phrase: "remind me to {what}.", command: "remind me to call mom.", slots: Slots{"what": "call mom"}, ok: true},
+	}
+
+	for _, tt := range tests {
+		slots, ok := matchPhrase(tt.phrase, tt.command)
+		if ok != tt.ok {
+			t.Errorf("matchPhrase(%q, %q) matched = %v, want %v", tt.phrase, tt.command, ok, tt.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(slots, tt.slots) {
+			t.Errorf("matchPhrase(%q, %q) = %v, want %v", tt.phrase, tt.command, slots, tt.slots)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	on := &Intent{Command: "turn on the lights", Alternatives: []string{"turn on the {room} lights"}}
+	off := &Intent{Command: "turn off the lights"}
+	list := []*Intent{on, off}
+
+	tests := []struct {
+		command string
+		intent  *Intent
+		phrase  string
+		slots   Slots
+	}{
+		{command: "Turn on the lights", intent: on, phrase: "turn on the lights", slots: Slots{}},
+		{command: "turn on the kitchen lights", intent: on, phrase: "turn on the {room} lights", slots: Slots{"room": "kitchen"}},
+		{command: "turn off the lights", intent: off, phrase: "turn off the lights", slots: Slots{}},
+		{command: "turn off the kitchen lights"},
+	}
+
+	for _, tt := range tests {
+		match := Find(list, tt.command)
+		if tt.intent == nil {
+			if match != nil {
+				t.Errorf("Find(%q) = %q, want no match", tt.command, match.Phrase)
+			}
+			continue
+		}
+		if match == nil {
+			t.Errorf("Find(%q) found no match, want %q", tt.command, tt.phrase)
+			continue
+		}
+		if match.Intent != tt.intent || match.Phrase != tt.phrase || match.Score != 1 || !reflect.DeepEqual(match.Slots, tt.slots) {
+			t.Errorf("Find(%q) = %q %v with score %v, want %q %v", tt.command, match.Phrase, match.Slots, match.Score, tt.phrase, tt.slots)
+		}
+	}
+}
+
+func TestSlotNames(t *testing.T) {
+	intent := &Intent{
+		Command:      "set the lights to {level} percent",
+		Alternatives: []string{"set the {room} lights to {level} percent"},
+		Prompts:      map[string]string{"level": "How bright?"},
+	}
+	want := []string{"level", "room"}
+	if got := intent.SlotNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SlotNames() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+
+package intents
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dlsniper/phas/i18n"
+)
+
+//Registry holds the Intents the user can choose from. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	intents []*Intent
+}
+
+//NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// phraseKey returns the key under which two phrases conflict, regardless of the names of their placeholders
+func phraseKey(intent *Intent, phrase string) string {
+	parts := placeholder.Split(phrase, -1)
+	for idx := range parts {
+		parts[idx] = simplify(parts[idx])
+	}
+	return strings.ToLower(intent.Language) + ":" + strings.Join(parts, " {} ")
+}
+
+//CheckPhrases returns an error when two Intents use the same phrase in the same language.
+//Placeholders conflict regardless of their names.
+func CheckPhrases(list []*Intent) error {
+	phrases := map[string]*Intent{}
+	for _, intent := range list {
+		for _, phrase := range intent.phrases() {
+			key := phraseKey(intent, phrase)
+			if other, ok := phrases[key]; ok && other != intent {
+				return fmt.Errorf("phrase %q is used by both %q and %q", phrase, other.Command, intent.Command)
+			}
+			phrases[key] = intent
+		}
+	}
+	return nil
+}
+
+// sortByPriority orders the Intents from the highest priority to the lowest, keeping the order of the equal ones
+func sortByPriority(list []*Intent) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Priority > list[j].Priority
+	})
+}
+
+//Register adds the Intent, unless one of its phrases is already used by another Intent
+func (r *Registry) Register(intent *Intent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := append(append([]*Intent(nil), r.intents...), intent)
+	if err := CheckPhrases(list); err != nil {
+		return err
+	}
+	sortByPriority(list)
+	r.intents = list
+	return nil
+}
+
+//Unregister removes the Intents with the given command, and returns how many were removed
+func (r *Registry) Unregister(command string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var list []*Intent
+	for _, intent := range r.intents {
+		if !strings.EqualFold(intent.Command, command) {
+			list = append(list, intent)
+		}
+	}
+	removed := len(r.intents) - len(list)
+	r.intents = list
+	return removed
+}
+
+//Replace replaces all the Intents at once, e.g. when the configuration is reloaded.
+//When two of the Intents use the same phrase, the current Intents are kept.
+func (r *Registry) Replace(list []*Intent) error {
+	if err := CheckPhrases(list); err != nil {
+		return err
+	}
+	list = append([]*Intent(nil), list...)
+	sortByPriority(list)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.intents = list
+	return nil
+}
+
+//List returns the registered Intents, from the highest priority to the lowest
+func (r *Registry) List() []*Intent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*Intent(nil), r.intents...)
+}
+
+//Match converts the given command to the closest Intent.
+//When no Intent scores above the ConfirmThreshold, an Intent that tells the user so is returned.
+//Only the Intents in the language of the context are considered, and on equal scores, the higher priority wins.
+func (r *Registry) Match(ctx context.Context, command string) *Match {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	language := i18n.Language(ctx)
+	best := &Match{}
+	for _, intent := range r.intents {
+		if !i18n.Same(intent.Language, language) {
+			continue
+		}
+		match := intent.BestMatch(ctx, command)
+		if match.Score > best.Score {
+			best = match
+		}
+		if best.Score == 1 {
+			break
+		}
+	}
+
+	if best.Score < ConfirmThreshold {
+		return &Match{
+			Intent: noMatchingIntent,
+			Slots:  Slots{},
+			Score:  best.Score,
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,158 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package intents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/normalize"
+)
+
+//Source tells where a command comes from
+type Source string
+
+//The sources a command can come from
+const (
+	SourceVoice    Source = "voice"
+	SourceHTTP     Source = "http"
+	SourceSMS      Source = "sms"
+	SourceSchedule Source = "schedule"
+	SourceText     Source = "text"
+)
+
+//A Request holds everything an Action needs to know about the command that triggered it
+type Request struct {
+	//Transcript is the command as it was received, e.g. from the speech to text service
+	Transcript string
+	//Phrase is the Intent phrase that matched the Transcript
+	Phrase string
+	//Slots holds the values of the placeholders in the Phrase
+	Slots Slots
+	//Source tells where the command comes from
+	Source Source
+	//WakeWord is the wakeword that triggered a voice command
+	WakeWord string
+	//Language is the language the command was given in, such as "en-US". When empty, i18n.Default is used.
+	Language string
+	//Dialog asks the user follow-up questions. It is nil when the source does not support them.
+	Dialog Dialog
+	//Latencies holds how long each stage of handling the command took, e.g. "recording" or "stt"
+	Latencies map[string]time.Duration
+	//Results holds the outcome of each Intent that ran for the command
+	Results []*Result
+	//Done, when not nil, is closed once the command was handled
+	Done chan struct{}
+}
+
+//Measure records how long a stage of handling the command took
+func (r *Request) Measure(stage string, d time.Duration) {
+	if r.Latencies == nil {
+		r.Latencies = map[string]time.Duration{}
+	}
+	r.Latencies[stage] += d
+}
+
+//A Dialog lets an Action ask the user follow-up questions without a new wakeword
+type Dialog interface {
+	Ask(ctx context.Context, question string) (string, error)
+}
+
+//ErrNoDialog is returned when asking a question for a Request that does not support follow-up questions
+var ErrNoDialog = errors.New("follow-up questions are not supported for this command")
+
+//Ask asks the user a follow-up question and returns the answer
+func (r *Request) Ask(ctx context.Context, question string) (string, error) {
+	if r == nil || r.Dialog == nil {
+		return "", ErrNoDialog
+	}
+	return r.Dialog.Ask(ctx, question)
+}
+
+//Confirm asks the user a yes or no question. Any failure to get an answer counts as a no.
+func (r *Request) Confirm(ctx context.Context, question string) bool {
+	answer, err := r.Ask(ctx, question)
+	return err == nil && IsAffirmative(answer)
+}
+
+//IsAffirmative tells if the answer of the user means yes
+func IsAffirmative(answer string) bool {
+	switch strings.Trim(strings.ToLower(answer), " .!") {
+	case "yes", "yeah", "yep", "sure", "correct", "ok", "okay", "yes please", "do it",
+		"da", "sigur", "desigur", "bine", "corect", "da te rog":
+		return true
+	}
+	return false
+}
+
+//Slot returns the value of the named placeholder, or an empty string if it was not captured
+func (r *Request) Slot(name string) string {
+	if r == nil {
+		return ""
+	}
+	return r.Slots[name]
+}
+
+func slotError(name string, err error) error {
+	return fmt.Errorf("the %s is not valid: %w", name, err)
+}
+
+//Number returns the value of the named placeholder as a number, such as 40 for "forty"
+func (r *Request) Number(name string) (float64, error) {
+	n, err := normalize.Number(r.Slot(name))
+	if err != nil {
+		return 0, slotError(name, err)
+	}
+	return n, nil
+}
+
+//Percent returns the value of the named placeholder as a percentage between 0 and 100
+func (r *Request) Percent(name string) (int, error) {
+	n, err := normalize.Percent(r.Slot(name))
+	if err != nil {
+		return 0, slotError(name, err)
+	}
+	return n, nil
+}
+
+//Ordinal returns the value of the named placeholder as an ordinal, such as 3 for "third"
+func (r *Request) Ordinal(name string) (int, error) {
+	n, err := normalize.Ordinal(r.Slot(name))
+	if err != nil {
+		return 0, slotError(name, err)
+	}
+	return n, nil
+}
+
+//Duration returns the value of the named placeholder as a duration, such as 90 minutes for "an hour and a half"
+func (r *Request) Duration(name string) (time.Duration, error) {
+	d, err := normalize.Duration(r.Slot(name))
+	if err != nil {
+		return 0, slotError(name, err)
+	}
+	return d, nil
+}
+
+//Clock returns the next occurrence after now of the time of the day in the named placeholder
+func (r *Request) Clock(name string, now time.Time) (time.Time, error) {
+	t, err := normalize.Clock(r.Slot(name), now)
+	if err != nil {
+		return time.Time{}, slotError(name, err)
+	}
+	return t, nil
+}
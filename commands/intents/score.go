@@ -0,0 +1,117 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package intents
+
+import (
+	"strings"
+	"unicode"
+)
+
+//AcceptThreshold is the score from which a command is matched to an Intent without asking the user
+var AcceptThreshold = 0.85
+
+//ConfirmThreshold is the lowest score for which the user is asked to confirm the Intent
+var ConfirmThreshold = 0.6
+
+// tokenThreshold is the similarity from which two words are considered the same, e.g. "light" and "lights"
+const tokenThreshold = 0.75
+
+func simplify(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	res := values[0]
+	for _, v := range values[1:] {
+		if v < res {
+			res = v
+		}
+	}
+	return res
+}
+
+// similarity returns the edit distance between two strings as a value between 0 and 1
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// tokenScore sums the best similarity of each token against the other tokens
+func tokenScore(tokens, others []string) float64 {
+	total := 0.0
+	for _, token := range tokens {
+		best := 0.0
+		for _, other := range others {
+			if s := similarity(token, other); s > best {
+				best = s
+			}
+		}
+		if best >= tokenThreshold {
+			total += best
+		}
+	}
+	return total
+}
+
+// score returns how close the command is to the phrase, where 1 means an exact match
+func score(phrase, command string) float64 {
+	phrase, command = simplify(phrase), simplify(command)
+	if phrase == command {
+		return 1
+	}
+
+	pt, ct := strings.Fields(phrase), strings.Fields(command)
+	if len(pt)+len(ct) == 0 {
+		return 0
+	}
+	tokens := (tokenScore(pt, ct) + tokenScore(ct, pt)) / float64(len(pt)+len(ct))
+
+	return 0.6*tokens + 0.4*similarity(phrase, command)
+}
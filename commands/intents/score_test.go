@@ -0,0 +1,115 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package intents
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	tests := map[string]string{
+		"Turn on the lights!":            "turn on the lights",
+		"  set   the lights, now ":       "set the lights now",
+		"Aprinde luminile în sufragerie": "aprinde luminile în sufragerie",
+		"40%":                            "40",
+	}
+	for text, want := range tests {
+		if got := simplify(text); got != want {
+			t.Errorf("simplify(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"lights", "lights", 1},
+		{"", "", 1},
+		{"light", "lights", 1 - 1.0/6},
+		{"abc", "xyz", 0},
+		{"în", "in", 0.5},
+	}
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	phrase := "turn off the lights"
+	if s := score(phrase, "Turn off the lights."); s != 1 {
+		t.Errorf("score of the same phrase = %v, want 1", s)
+	}
+
+	close := score(phrase, "turn of the light")
+	if close < ConfirmThreshold {
+		t.Errorf("score of a misheard command = %v, want at least %v", close, ConfirmThreshold)
+	}
+	extra := score(phrase, "turn off the lights in the kitchen")
+	if extra >= AcceptThreshold || extra >= close {
+		t.Errorf("score of a command with extra words = %v, want below %v and %v", extra, AcceptThreshold, close)
+	}
+	if s := score(phrase, "what time is it"); s >= ConfirmThreshold {
+		t.Errorf("score of an unrelated command = %v, want below %v", s, ConfirmThreshold)
+	}
+}
+
+func TestRegistryMatch(t *testing.T) {
+	off := &Intent{Command: "turn off the lights", Alternatives: []string{"turn off the lights in the {room}"}}
+	color := &Intent{Command: "set the {room} to {color}", Priority: 1}
+	level := &Intent{Command: "set the lights to {level} percent", Priority: 2}
+	other := &Intent{Command: "tell me a joke"}
+	r := NewRegistry()
+	if err := r.Replace([]*Intent{off, color, level, other}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command string
+		intent  *Intent
+		slots   Slots
+		confirm bool
+	}{
+		{command: "turn off the lights", intent: off, slots: Slots{}},
+		{command: "turn off the lights in the kitchen", intent: off, slots: Slots{"room": "kitchen"}},
+		{command: "set the lights to 50 percent", intent: level, slots: Slots{"level": "50"}},
+		{command: "set the kitchen to red", intent: color, slots: Slots{"room": "kitchen", "color": "red"}},
+		{command: "tell me a joke please", intent: other, slots: Slots{}, confirm: true},
+		{command: "what time is it", intent: noMatchingIntent, slots: Slots{}},
+	}
+
+	for _, tt := range tests {
+		match := r.Match(context.Background(), tt.command)
+		if match.Intent != tt.intent {
+			t.Errorf("Match(%q) = %q, want %q", tt.command, match.Intent.Command, tt.intent.Command)
+			continue
+		}
+		if len(match.Slots) != len(tt.slots) {
+			t.Errorf("Match(%q) slots = %v, want %v", tt.command, match.Slots, tt.slots)
+		}
+		for name, value := range tt.slots {
+			if match.Slots[name] != value {
+				t.Errorf("Match(%q) slot %s = %q, want %q", tt.command, name, match.Slots[name], value)
+			}
+		}
+		if match.NeedsConfirmation() != tt.confirm {
+			t.Errorf("Match(%q) needs confirmation = %v, want %v (score %v)", tt.command, match.NeedsConfirmation(), tt.confirm, match.Score)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/hue"
+	"github.com/dlsniper/phas/lights"
+	"github.com/dlsniper/phas/mqtt"
+	"github.com/dlsniper/phas/plugins"
+	"github.com/dlsniper/phas/routines"
+	"github.com/dlsniper/phas/scheduler"
+	"github.com/dlsniper/phas/wled"
+)
+
+//Config holds the PHAS configuration file contents
+type Config struct {
+	Intents   []intents.Definition   `json:"intents,omitempty"`
+	Routines  []routines.Definition  `json:"routines,omitempty"`
+	Schedules []scheduler.Definition `json:"schedules,omitempty"`
+	Plugins   []plugins.Definition   `json:"plugins,omitempty"`
+	//Hue is the paired hue bridge, used when PHAS_HUE_ADDR and PHAS_HUE_USER are not set
+	Hue *hue.Bridge `json:"hue,omitempty"`
+	//WLED are the WLED lights, controlled through their JSON API
+	WLED []wled.Device `json:"wled,omitempty"`
+	//MQTT is the broker of the lights which use the zigbee2mqtt messages
+	MQTT *mqtt.Config `json:"mqtt,omitempty"`
+	//Alarms are the light alarm patterns, where the first one is the default
+	Alarms []lights.AlarmDefinition `json:"alarms,omitempty"`
+	//Translations holds the responses in other languages, keyed by language and then by the English response
+	Translations map[string]map[string]string `json:"translations,omitempty"`
+}
+
+//Load reads the configuration from the given file
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(path, b)
+}
+
+func parse(path string, b []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	cfg := &Config{}
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+//Set changes a top level setting of the configuration file, such as "hue", and keeps the other ones.
+//The file is created when missing.
+func Set(path, key string, value interface{}) error {
+	settings := map[string]json.RawMessage{}
+	mode := os.FileMode(0600)
+	b, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, &settings); err != nil {
+			return fmt.Errorf("invalid configuration file %s: %w", path, err)
+		}
+		if fi, err := os.Stat(path); err == nil {
+			mode = fi.Mode().Perm()
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	settings[key] = v
+
+	b, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := parse(path, b); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a failure doesn't leave a broken configuration behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+//Watch calls onChange with the new configuration whenever the file changes or the process receives SIGHUP
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func(*Config)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	modTime := func() time.Time {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return fi.ModTime()
+	}
+
+	reload := func() {
+		cfg, err := Load(path)
+		if err != nil {
+			log.Printf("failed to reload the configuration: %v\n", err)
+			return
+		}
+		log.Printf("reloaded the configuration from %s\n", path)
+		onChange(cfg)
+	}
+
+	lastMod := modTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			lastMod = modTime()
+			reload()
+		case <-ticker.C:
+			if mod := modTime(); !mod.Equal(lastMod) {
+				lastMod = mod
+				reload()
+			}
+		}
+	}
+}
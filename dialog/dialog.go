@@ -0,0 +1,228 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dialog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlsniper/phas/rv"
+	"github.com/dlsniper/phas/stt"
+	"github.com/dlsniper/phas/tts"
+	"github.com/dlsniper/phas/wakeword"
+)
+
+//ErrNoAnswer is returned when the user did not answer in time
+var ErrNoAnswer = errors.New("no answer from the user")
+
+//Session owns the microphone and lets intents ask follow-up questions without a new wakeword
+type Session struct {
+	tts      *tts.Service
+	listener *rv.Service
+	stt      *stt.Service
+	timeout  time.Duration
+
+	mic           sync.Mutex
+	stopListening func()
+}
+
+//New creates a new Session which waits up to timeout for each answer
+func New(ttsService *tts.Service, listener *rv.Service, sttService *stt.Service, timeout time.Duration) *Session {
+	return &Session{
+		tts:      ttsService,
+		listener: listener,
+		stt:      sttService,
+		timeout:  timeout,
+	}
+}
+
+//WaitForWakeWord listens for a wakeword. The listening pauses whenever the microphone is needed for an answer.
+func (s *Session) WaitForWakeWord(ctx context.Context, ww *wakeword.Listener) (string, context.Context) {
+	for {
+		lctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+
+		s.mic.Lock()
+		s.stopListening = func() {
+			cancel()
+			<-done
+		}
+		s.mic.Unlock()
+
+		word, _ := ww.Listen(lctx)
+		close(done)
+		cancel()
+
+		s.mic.Lock()
+		s.stopListening = nil
+		s.mic.Unlock()
+
+		if word != "" || ctx.Err() != nil {
+			return word, ctx
+		}
+	}
+}
+
+//Utterance is what the user said and in which language, together with how long it took to record and recognize it
+type Utterance struct {
+	Text        string
+	Language    string
+	Recording   time.Duration
+	Recognition time.Duration
+}
+
+//Record records the user until something is said or the Session timeout passes
+func (s *Session) Record(ctx context.Context) (*Utterance, error) {
+	s.mic.Lock()
+	defer s.mic.Unlock()
+
+	if s.stopListening != nil {
+		s.stopListening()
+		s.stopListening = nil
+	}
+
+	res := &Utterance{}
+	deadline := time.Now().Add(s.timeout)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		content := s.listener.Listen()
+		res.Recording += time.Since(start)
+
+		start = time.Now()
+		text, language, err := s.stt.Process(ctx, content)
+		res.Recognition += time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+		res.Text, res.Language = strings.TrimSpace(text), language
+
+		if res.Text != "" {
+			return res, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrNoAnswer
+		}
+	}
+}
+
+//Listen records the user and returns what was said
+func (s *Session) Listen(ctx context.Context) (string, error) {
+	u, err := s.Record(ctx)
+	if err != nil {
+		return "", err
+	}
+	return u.Text, nil
+}
+
+//Ask speaks the question and returns the answer of the user
+func (s *Session) Ask(ctx context.Context, question string) (string, error) {
+	s.tts.Speak(ctx, question)
+	answer, err := s.Listen(ctx)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("got answer: %q\n", answer)
+	return answer, nil
+}
+
+//Console asks the questions and reads the answers in a terminal, for running PHAS without a microphone.
+//The lines are read in the background, so that Interrupt sees them while a command runs.
+type Console struct {
+	tts *tts.Service
+	in  *bufio.Scanner
+	out io.Writer
+
+	//Interrupt, when set before the first line is read, handles the lines typed at any time, such as "stop".
+	//It returns true for the lines it handled, and those are not returned by ReadLine or Ask.
+	Interrupt func(line string) bool
+
+	start sync.Once
+	lines chan string
+	err   error
+}
+
+//NewConsole creates a new Console which reads the user input from in and writes the prompts to out
+func NewConsole(ttsService *tts.Service, in io.Reader, out io.Writer) *Console {
+	return &Console{
+		tts:   ttsService,
+		in:    bufio.NewScanner(in),
+		out:   out,
+		lines: make(chan string, 100),
+	}
+}
+
+// read sends the typed lines to ReadLine and Ask, until the input ends
+func (c *Console) read() {
+	for c.in.Scan() {
+		line := c.in.Text()
+		if c.Interrupt != nil && c.Interrupt(line) {
+			continue
+		}
+		c.lines <- line
+	}
+	c.err = c.in.Err()
+	close(c.lines)
+}
+
+// next returns the next line typed, unless the context is done first
+func (c *Console) next(ctx context.Context) (string, error) {
+	c.start.Do(func() {
+		go c.read()
+	})
+
+	_, _ = fmt.Fprint(c.out, "> ")
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case line, ok := <-c.lines:
+		if !ok {
+			if c.err != nil {
+				return "", c.err
+			}
+			return "", io.EOF
+		}
+		return line, nil
+	}
+}
+
+//ReadLine prompts the user and returns the next line typed
+func (c *Console) ReadLine() (string, error) {
+	return c.next(context.Background())
+}
+
+//Ask prints the question and returns the next line typed
+func (c *Console) Ask(ctx context.Context, question string) (string, error) {
+	c.tts.Speak(ctx, question)
+	answer, err := c.next(ctx)
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", ErrNoAnswer
+	}
+	return answer, nil
+}
@@ -18,7 +18,7 @@ import (
 	"context"
 	"log"
 
-	speech "cloud.google.com/go/speech/apiv1"
+	speech "cloud.google.com/go/speech/apiv1p1beta1"
 	texttospeech "cloud.google.com/go/texttospeech/apiv1"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/cloudkms/v1"
@@ -0,0 +1,199 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amimof/huego"
+	"github.com/dlsniper/phas/lights"
+)
+
+// target is either a group of lights, such as a room, or a single light
+type target struct {
+	group *huego.Group
+	light *huego.Light
+}
+
+// target finds a group, or else a light, by name. An empty name means all the lights.
+func (s *Service) target(ctx context.Context, name string) (*target, error) {
+	if s.bridge == nil {
+		return nil, ErrNotConfigured
+	}
+	if name == "" {
+		g, err := s.bridge.GetGroupContext(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &target{group: g}, nil
+	}
+
+	gs, err := s.bridge.GetGroupsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range gs {
+		if strings.EqualFold(gs[idx].Name, name) {
+			return &target{group: &gs[idx]}, nil
+		}
+	}
+
+	ls, err := s.bridge.GetLightsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range ls {
+		if strings.EqualFold(ls[idx].Name, name) {
+			return &target{light: &ls[idx]}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("there is no room or light called %s", name)
+}
+
+func (t *target) setState(ctx context.Context, state huego.State) error {
+	if t.group != nil {
+		return t.group.SetStateContext(ctx, state)
+	}
+	return t.light.SetStateContext(ctx, state)
+}
+
+// state reads the state of the target again from the bridge
+func (s *Service) state(ctx context.Context, t *target) (*lights.State, error) {
+	if t.group != nil {
+		return s.groupState(ctx, t.group.ID)
+	}
+
+	l, err := s.bridge.GetLightContext(ctx, t.light.ID)
+	if err != nil {
+		return nil, err
+	}
+	res := &lights.State{Name: l.Name, Light: true}
+	if l.State != nil {
+		res.On = l.State.On
+		if res.On {
+			res.Brightness = percent(l.State.Bri)
+		}
+	}
+	return res, nil
+}
+
+func (s *Service) groupState(ctx context.Context, id int) (*lights.State, error) {
+	g, err := s.bridge.GetGroupContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &lights.State{Name: g.Name, All: id == 0}
+	if g.GroupState != nil {
+		res.On = g.GroupState.AnyOn
+	}
+	if g.State != nil && res.On {
+		res.Brightness = percent(g.State.Bri)
+	}
+	return res, nil
+}
+
+func percent(bri uint8) int {
+	return (int(bri)*100 + 127) / 254
+}
+
+// hueState converts the change to the state sent to the bridge
+func hueState(c lights.Change) (huego.State, error) {
+	state := huego.State{On: !c.Off}
+	if c.Transition > 0 {
+		state.TransitionTime = transitionTime(c.Transition)
+	}
+	if c.Off {
+		return state, nil
+	}
+
+	state.Bri = c.Bri()
+	if c.Kelvin > 0 {
+		state.Ct = lights.Mired(c.Kelvin)
+	}
+	if c.Color != "" {
+		color, err := lights.LookupColor(c.Color)
+		if err != nil {
+			return state, err
+		}
+		if color.Kelvin > 0 {
+			state.Ct = lights.Mired(color.Kelvin)
+		} else {
+			state.Xy = color.XY
+		}
+	}
+	return state, nil
+}
+
+//Name of the backend
+func (s *Service) Name() string {
+	return "hue"
+}
+
+//Devices returns the groups and the lights known by the bridge
+func (s *Service) Devices(ctx context.Context) ([]lights.Device, error) {
+	groups, err := s.Groups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ls, err := s.Lights(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]lights.Device, 0, len(groups)+len(ls))
+	for _, g := range groups {
+		res = append(res, lights.Device{Name: g.Name, Group: true, Backend: s.Name()})
+	}
+	for _, l := range ls {
+		res = append(res, lights.Device{Name: l.Name, Backend: s.Name()})
+	}
+	return res, nil
+}
+
+//Set changes the light or the group, such as a room, with the given name.
+//An empty name means all the lights. It returns the state after the change.
+func (s *Service) Set(ctx context.Context, name string, change lights.Change) (*lights.State, error) {
+	state, err := hueState(change)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := s.target(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.setState(ctx, state); err != nil {
+		return nil, err
+	}
+
+	return s.state(ctx, t)
+}
+
+// transitionTime converts the duration to the steps of 100ms used by the bridge
+func transitionTime(d time.Duration) uint16 {
+	steps := (d + 50*time.Millisecond) / (100 * time.Millisecond)
+	switch {
+	case steps < 1:
+		steps = 1
+	case steps > 65535:
+		steps = 65535
+	}
+	return uint16(steps)
+}
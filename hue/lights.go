@@ -15,8 +15,7 @@
 package hue
 
 import (
-	"fmt"
-	"time"
+	"errors"
 
 	"github.com/amimof/huego"
 )
@@ -26,6 +25,9 @@ type Service struct {
 	bridge *huego.Bridge
 }
 
+//ErrNotConfigured is returned when the address or the user of the bridge are missing
+var ErrNotConfigured = errors.New("the hue bridge is not configured")
+
 //New creates a new hue Service
 func New(address, user string) *Service {
 	res := &Service{}
@@ -36,45 +38,7 @@ func New(address, user string) *Service {
 	return res
 }
 
-//Alarm triggers the alarm
-func (s *Service) Alarm(groupName string) error {
-	if s.bridge == nil {
-		return nil
-	}
-	gs, err := s.bridge.GetGroups()
-	if err != nil {
-		return err
-	}
-
-	gr := huego.Group{}
-	for _, g := range gs {
-		if g.Name == groupName {
-			gr = g
-			break
-		}
-	}
-	if gr.Name != groupName {
-		return fmt.Errorf("group not found")
-	}
-
-	iState := *gr.State
-
-	state := huego.State{
-		On:    true,
-		Alert: "lselect",
-		Bri:   254,
-		Sat:   254,
-		Hue:   65535,
-	}
-	err = gr.SetState(state)
-	if err != nil {
-		return err
-	}
-
-	time.Sleep(16 * time.Second)
-
-	// Reset the alarm state and prevent the color mode from erroring out
-	iState.Alert = "none"
-	iState.ColorMode = ""
-	return gr.SetState(iState)
+//Configured tells if the address and the user of the bridge are set
+func (s *Service) Configured() bool {
+	return s.bridge != nil
 }
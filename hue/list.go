@@ -0,0 +1,109 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hue
+
+import (
+	"context"
+	"sort"
+)
+
+//Light is a light known by the bridge
+type Light struct {
+	ID        int
+	Name      string
+	On        bool
+	Reachable bool
+}
+
+//Group is a group of lights known by the bridge, such as a room or a zone
+type Group struct {
+	ID   int
+	Name string
+	Type string
+	//Lights are the IDs of the lights in the group
+	Lights []string
+}
+
+//Scene is a scene known by the bridge
+type Scene struct {
+	ID   string
+	Name string
+	//Group is the ID of the group of the scene, if any
+	Group string
+}
+
+//Lights returns the lights known by the bridge, sorted by name
+func (s *Service) Lights(ctx context.Context) ([]Light, error) {
+	if s.bridge == nil {
+		return nil, ErrNotConfigured
+	}
+	ls, err := s.bridge.GetLightsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Light, 0, len(ls))
+	for _, l := range ls {
+		light := Light{ID: l.ID, Name: l.Name}
+		if l.State != nil {
+			light.On = l.State.On
+			light.Reachable = l.State.Reachable
+		}
+		res = append(res, light)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+//Groups returns the groups known by the bridge, sorted by name
+func (s *Service) Groups(ctx context.Context) ([]Group, error) {
+	if s.bridge == nil {
+		return nil, ErrNotConfigured
+	}
+	gs, err := s.bridge.GetGroupsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Group, 0, len(gs))
+	for _, g := range gs {
+		res = append(res, Group{ID: g.ID, Name: g.Name, Type: g.Type, Lights: g.Lights})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+//Scenes returns the scenes known by the bridge, sorted by name
+func (s *Service) Scenes(ctx context.Context) ([]Scene, error) {
+	if s.bridge == nil {
+		return nil, ErrNotConfigured
+	}
+	ss, err := s.bridge.GetScenesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Scene, 0, len(ss))
+	for _, sc := range ss {
+		res = append(res, Scene{ID: sc.ID, Name: sc.Name, Group: sc.Group})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
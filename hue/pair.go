@@ -0,0 +1,232 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/amimof/huego"
+)
+
+//DiscoveryURL is the Philips service which lists the bridges on the local network
+const DiscoveryURL = "https://discovery.meethue.com"
+
+// ssdpAddr is where the bridges answer SSDP searches
+const ssdpAddr = "239.255.255.250:1900"
+
+// linkButtonNotPressed is the error type returned by the bridge until its link button is pressed
+const linkButtonNotPressed = 101
+
+//Bridge holds the address of a bridge, and the user of its API once paired
+type Bridge struct {
+	ID      string `json:"id,omitempty"`
+	Address string `json:"address"`
+	User    string `json:"user,omitempty"`
+}
+
+//Discover finds the bridges on the local network, both with SSDP and with the discovery service at the URL.
+//An empty URL only uses SSDP. The search lasts at most the timeout.
+func Discover(ctx context.Context, discoveryURL string, timeout time.Duration) ([]Bridge, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		bridges []Bridge
+		err     error
+	}
+	results := make(chan result, 2)
+	go func() {
+		bridges, err := discoverSSDP(ctx)
+		results <- result{bridges, err}
+	}()
+	searches := 1
+	if discoveryURL != "" {
+		searches++
+		go func() {
+			bridges, err := discoverService(ctx, discoveryURL)
+			results <- result{bridges, err}
+		}()
+	}
+
+	var res []Bridge
+	var errs []string
+	seen := map[string]bool{}
+	for i := 0; i < searches; i++ {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err.Error())
+		}
+		for _, b := range r.bridges {
+			if !seen[b.Address] {
+				seen[b.Address] = true
+				res = append(res, b)
+			}
+		}
+	}
+
+	if len(res) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("failed to discover the hue bridges: %s", strings.Join(errs, "; "))
+	}
+	return res, nil
+}
+
+// discoverService asks the discovery service for the bridges on the network
+func discoverService(ctx context.Context, discoveryURL string) ([]Bridge, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the discovery service answered with %s", resp.Status)
+	}
+
+	var found []struct {
+		ID      string `json:"id"`
+		Address string `json:"internalipaddress"`
+		Port    int    `json:"port"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return nil, fmt.Errorf("invalid answer from the discovery service: %w", err)
+	}
+
+	res := make([]Bridge, 0, len(found))
+	for _, f := range found {
+		b := Bridge{ID: strings.ToLower(f.ID), Address: f.Address}
+		// The bridges use HTTPS on 443, which PHAS doesn't use, so only other ports are kept
+		if f.Port != 0 && f.Port != 80 && f.Port != 443 {
+			b.Address = net.JoinHostPort(f.Address, fmt.Sprint(f.Port))
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+// discoverSSDP sends an SSDP search and collects the answers of the bridges until the context is done
+func discoverSSDP(ctx context.Context) ([]Bridge, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: ssdp:all\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), dst); err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var res []Bridge
+	seen := map[string]bool{}
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			// The search ends with the deadline
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return res, nil
+			}
+			return res, err
+		}
+
+		b, ok := parseSSDP(buf[:n])
+		if ok && !seen[b.Address] {
+			seen[b.Address] = true
+			res = append(res, b)
+		}
+	}
+}
+
+// parseSSDP reads the bridge from an SSDP answer. Only the hue bridges have the hue-bridgeid header.
+func parseSSDP(answer []byte) (Bridge, bool) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(answer)), nil)
+	if err != nil {
+		return Bridge{}, false
+	}
+	resp.Body.Close()
+
+	id := resp.Header.Get("hue-bridgeid")
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if id == "" || err != nil || location.Host == "" {
+		return Bridge{}, false
+	}
+
+	address := location.Host
+	if location.Port() == "80" {
+		address = location.Hostname()
+	}
+	return Bridge{ID: strings.ToLower(id), Address: address}, true
+}
+
+//Pair creates a user for PHAS on the bridge at the address, and returns the paired bridge.
+//The bridge only accepts it after its link button is pressed, so Pair tries again at every interval,
+//until the context is done. It calls pressButton once, when the button needs to be pressed.
+func Pair(ctx context.Context, address, name string, interval time.Duration, pressButton func()) (*Bridge, error) {
+	bridge := huego.New(address, "")
+	asked := false
+	for {
+		user, err := bridge.CreateUserContext(ctx, name)
+		if err == nil {
+			return &Bridge{Address: address, User: user}, nil
+		}
+
+		var apiErr *huego.APIError
+		if !errors.As(err, &apiErr) || apiErr.Type != linkButtonNotPressed {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("the link button of the bridge was not pressed in time")
+			}
+			return nil, err
+		}
+		if !asked {
+			asked = true
+			pressButton()
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, fmt.Errorf("the link button of the bridge was not pressed in time")
+		case <-t.C:
+		}
+	}
+}
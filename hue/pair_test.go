@@ -0,0 +1,214 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiscover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/discovery" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`[
+			{"id": "001788FFFE23BFC2", "internalipaddress": "192.168.1.10", "port": 443},
+			{"id": "001788FFFE23BFC3", "internalipaddress": "192.168.1.11", "port": 8080},
+			{"id": "001788FFFE23BFC2", "internalipaddress": "192.168.1.10"}
+		]`))
+	}))
+	defer srv.Close()
+
+	bridges, err := Discover(context.Background(), srv.URL+"/discovery", 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+
+	// SSDP can also find bridges, so only the ones of the discovery service are checked
+	found := map[string]Bridge{}
+	for _, b := range bridges {
+		if _, ok := found[b.Address]; ok {
+			t.Errorf("the bridge at %s was found twice", b.Address)
+		}
+		found[b.Address] = b
+	}
+	for _, want := range []Bridge{
+		{ID: "001788fffe23bfc2", Address: "192.168.1.10"},
+		{ID: "001788fffe23bfc3", Address: "192.168.1.11:8080"},
+	} {
+		if got := found[want.Address]; got != want {
+			t.Errorf("got %+v for %s, want %+v", got, want.Address, want)
+		}
+	}
+}
+
+func TestDiscoverServiceErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"status", http.StatusInternalServerError, ""},
+		{"invalid answer", http.StatusOK, `{"id": "001788fffe23bfc2"}`},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			_, _ = w.Write([]byte(tt.body))
+		}))
+		if _, err := discoverService(context.Background(), srv.URL); err == nil {
+			t.Errorf("%s: discoverService succeeded, want an error", tt.name)
+		}
+		srv.Close()
+	}
+}
+
+func TestParseSSDP(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   Bridge
+		ok     bool
+	}{
+		{
+			answer: "HTTP/1.1 200 OK\r\nLOCATION: http://192.168.1.10:80/description.xml\r\nhue-bridgeid: 001788FFFE23BFC2\r\n\r\n",
+			want:   Bridge{ID: "001788fffe23bfc2", Address: "192.168.1.10"},
+			ok:     true,
+		},
+		{
+			answer: "HTTP/1.1 200 OK\r\nLOCATION: http://192.168.1.11:8080/description.xml\r\nhue-bridgeid: 001788FFFE23BFC3\r\n\r\n",
+			want:   Bridge{ID: "001788fffe23bfc3", Address: "192.168.1.11:8080"},
+			ok:     true,
+		},
+		{answer: "HTTP/1.1 200 OK\r\nLOCATION: http://192.168.1.12:80/description.xml\r\n\r\n"},
+		{answer: "HTTP/1.1 200 OK\r\nhue-bridgeid: 001788FFFE23BFC2\r\n\r\n"},
+		{answer: "NOTIFY * HTTP/1.1\r\n"},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseSSDP([]byte(tt.answer))
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSSDP(%q) = %+v, %v, want %+v, %v", tt.answer, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// fakeBridge answers the user creations with the link button error, until the button is pressed at the pressAt attempt
+type fakeBridge struct {
+	pressAt int
+	errType int
+
+	mu       sync.Mutex
+	attempts int
+	devices  []string
+}
+
+func (f *fakeBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/api" {
+		http.NotFound(w, r)
+		return
+	}
+	var body struct {
+		DeviceType string `json:"devicetype"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	f.devices = append(f.devices, body.DeviceType)
+	switch {
+	case f.errType != 0:
+		fmt.Fprintf(w, `[{"error": {"type": %d, "address": "/", "description": "failed"}}]`, f.errType)
+	case f.pressAt == 0 || f.attempts < f.pressAt:
+		fmt.Fprint(w, `[{"error": {"type": 101, "address": "", "description": "link button not pressed"}}]`)
+	default:
+		fmt.Fprint(w, `[{"success": {"username": "phas-user"}}]`)
+	}
+}
+
+func TestPair(t *testing.T) {
+	fake := &fakeBridge{pressAt: 3}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	address := strings.TrimPrefix(srv.URL, "http://")
+
+	pressed := 0
+	bridge, err := Pair(context.Background(), address, "phas#test", 10*time.Millisecond, func() { pressed++ })
+	if err != nil {
+		t.Fatalf("Pair failed: %v", err)
+	}
+	if want := (Bridge{Address: address, User: "phas-user"}); *bridge != want {
+		t.Errorf("got %+v, want %+v", *bridge, want)
+	}
+	if pressed != 1 {
+		t.Errorf("pressButton was called %d times, want once", pressed)
+	}
+	if fake.attempts != 3 {
+		t.Errorf("the bridge was asked %d times, want 3", fake.attempts)
+	}
+	for _, device := range fake.devices {
+		if device != "phas#test" {
+			t.Errorf("got the device type %q, want %q", device, "phas#test")
+		}
+	}
+}
+
+func TestPairNotPressed(t *testing.T) {
+	fake := &fakeBridge{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	pressed := 0
+	_, err := Pair(ctx, strings.TrimPrefix(srv.URL, "http://"), "phas#test", 10*time.Millisecond, func() { pressed++ })
+	if err == nil || !strings.Contains(err.Error(), "not pressed in time") {
+		t.Errorf("got the error %v, want the link button one", err)
+	}
+	if pressed != 1 {
+		t.Errorf("pressButton was called %d times, want once", pressed)
+	}
+	if fake.attempts < 2 {
+		t.Errorf("the bridge was asked %d times, want it to be asked again", fake.attempts)
+	}
+}
+
+func TestPairError(t *testing.T) {
+	fake := &fakeBridge{errType: 7}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	pressed := 0
+	_, err := Pair(context.Background(), strings.TrimPrefix(srv.URL, "http://"), "phas#test", 10*time.Millisecond, func() { pressed++ })
+	if err == nil {
+		t.Fatal("Pair succeeded, want an error")
+	}
+	if pressed != 0 || fake.attempts != 1 {
+		t.Errorf("pressButton was called %d times after %d attempts, want no call after one attempt", pressed, fake.attempts)
+	}
+}
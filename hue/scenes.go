@@ -0,0 +1,74 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dlsniper/phas/lights"
+)
+
+//ActivateScene recalls the scene with the given name. The room is optional, and tells which scene to use
+//when several rooms have one with the same name. It returns the state of the group after the change.
+func (s *Service) ActivateScene(ctx context.Context, name, room string) (*lights.State, error) {
+	name = strings.TrimSpace(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "the "))
+	name = strings.TrimSpace(strings.TrimSuffix(name, " scene"))
+
+	scenes, err := s.Scenes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var found []Scene
+	for _, sc := range scenes {
+		if strings.EqualFold(sc.Name, name) {
+			found = append(found, sc)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("there is no scene called %s", name)
+	}
+
+	scene, gid := found[0], 0
+	room = strings.TrimSpace(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(room)), "the "))
+	room = strings.TrimSpace(strings.TrimSuffix(room, " lights"))
+	if room != "" {
+		t, err := s.target(ctx, room)
+		if err != nil {
+			return nil, err
+		}
+		if t.group == nil {
+			return nil, fmt.Errorf("%s is a light, not a room", t.light.Name)
+		}
+		gid = t.group.ID
+		for _, sc := range found {
+			if sc.Group == strconv.Itoa(gid) {
+				scene = sc
+				break
+			}
+		}
+	} else if scene.Group != "" {
+		if gid, err = strconv.Atoi(scene.Group); err != nil {
+			return nil, fmt.Errorf("the scene %s has an invalid group %q", scene.Name, scene.Group)
+		}
+	}
+
+	if _, err := s.bridge.RecallSceneContext(ctx, scene.ID, gid); err != nil {
+		return nil, err
+	}
+	return s.groupState(ctx, gid)
+}
@@ -0,0 +1,95 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/amimof/huego"
+)
+
+//Snapshot saves the state of each light of the group, or of the light, with the given name,
+//and returns a function which restores it. An empty name means all the lights.
+func (s *Service) Snapshot(ctx context.Context, name string) (func(context.Context) error, error) {
+	t, err := s.target(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := s.saveLights(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) error {
+		return restoreLights(ctx, saved)
+	}, nil
+}
+
+// saveLights reads the state of each light of the target
+func (s *Service) saveLights(ctx context.Context, t *target) ([]*huego.Light, error) {
+	if t.light != nil {
+		return []*huego.Light{t.light}, nil
+	}
+
+	var res []*huego.Light
+	for _, id := range t.group.Lights {
+		lid, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid light %q in the group %s", id, t.group.Name)
+		}
+		l, err := s.bridge.GetLightContext(ctx, lid)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, nil
+}
+
+// restoreLights puts the lights back in their saved state, and returns the first error
+func restoreLights(ctx context.Context, saved []*huego.Light) error {
+	var firstErr error
+	for _, l := range saved {
+		if l.State == nil {
+			continue
+		}
+		state := huego.State{On: true, Bri: l.State.Bri}
+		switch l.State.ColorMode {
+		case "xy":
+			state.Xy = l.State.Xy
+		case "ct":
+			state.Ct = l.State.Ct
+		case "hs":
+			state.Hue, state.Sat = l.State.Hue, l.State.Sat
+		}
+
+		// The bridge doesn't change the color of the lights which are off, so they are turned off after.
+		// Setting the state also changes l.State.
+		wasOn := l.State.On
+		err := l.SetStateContext(ctx, state)
+		if err == nil && !wasOn {
+			err = l.SetStateContext(ctx, huego.State{On: false})
+		}
+		if err != nil {
+			log.Printf("failed to restore the state of the light %s: %v\n", l.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,111 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+
+//Package i18n keeps track of the language of each command, and translates the responses to it
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//Default is the language used when a command does not have one, such as the typed commands
+var Default = "en-US"
+
+type languageKey struct{}
+
+//WithLanguage returns a context in which the responses are given in the language
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+//Language returns the language of the context, or Default if it has none
+func Language(ctx context.Context) string {
+	if language, ok := ctx.Value(languageKey{}).(string); ok && language != "" {
+		return language
+	}
+	return Default
+}
+
+//base returns the language without the region, e.g. "ro" for "ro-RO"
+func base(language string) string {
+	language = strings.ToLower(language)
+	if idx := strings.IndexAny(language, "-_"); idx != -1 {
+		return language[:idx]
+	}
+	return language
+}
+
+//Same tells if two languages are the same, regardless of their region.
+//An empty language is the same as any other language.
+func Same(a, b string) bool {
+	return a == "" || b == "" || base(a) == base(b)
+}
+
+var (
+	mu           sync.RWMutex
+	translations = map[string]map[string]string{}
+	// configured holds the translations from the configuration file, which take precedence over the built-in ones
+	configured = map[string]map[string]string{}
+)
+
+//Register adds translations to a language. They are keyed by the English format of the message.
+func Register(language string, messages map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lang := base(language)
+	if translations[lang] == nil {
+		translations[lang] = map[string]string{}
+	}
+	for format, translation := range messages {
+		translations[lang][format] = translation
+	}
+}
+
+//Configure replaces the translations from the configuration file, keyed by language and then by the English format.
+//They take precedence over the registered ones, and the ones missing from messages are dropped.
+func Configure(messages map[string]map[string]string) {
+	res := map[string]map[string]string{}
+	for language, list := range messages {
+		lang := base(language)
+		if res[lang] == nil {
+			res[lang] = map[string]string{}
+		}
+		for format, translation := range list {
+			res[lang][format] = translation
+		}
+	}
+
+	mu.Lock()
+	configured = res
+	mu.Unlock()
+}
+
+//Sprintf formats the message in the language of the context, or in English if there is no translation for it
+func Sprintf(ctx context.Context, format string, args ...interface{}) string {
+	lang := base(Language(ctx))
+	mu.RLock()
+	if translation, ok := configured[lang][format]; ok {
+		format = translation
+	} else if translation, ok := translations[lang][format]; ok {
+		format = translation
+	}
+	mu.RUnlock()
+
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,71 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package i18n
+
+func init() {
+	Register("ro", map[string]string{
+		"I could not understand your request. Please try again.": "Nu am înțeles ce ai cerut. Te rog să încerci din nou.",
+		"Did you mean %s? Please answer yes or no.":              "Te-ai referit la %s? Te rog să răspunzi cu da sau nu.",
+		"OK, I will not do it.":                                  "Bine, nu o voi face.",
+		"I did %d of %d things.":                                 "Am făcut %d din %d lucruri.",
+		"All %d things are done.":                                "Toate cele %d lucruri sunt gata.",
+		"These failed: %s.":                                      "Acestea au eșuat: %s.",
+		"Sorry, %s failed: %s. I will continue.":                 "Îmi pare rău, %s a eșuat: %s. Voi continua.",
+		"Sorry, %s failed: %s.":                                  "Îmi pare rău, %s a eșuat: %s.",
+		"Sorry, %s failed: %s. Should I try again?":              "Îmi pare rău, %s a eșuat: %s. Să încerc din nou?",
+		"Sorry, I did not get an answer.":                        "Îmi pare rău, nu am primit niciun răspuns.",
+		"Starting the %s routine.":                               "Pornesc rutina %s.",
+		"There is no routine running.":                           "Nu rulează nicio rutină.",
+		"The routine is cancelled.":                              "Rutina a fost anulată.",
+		"Hello, Human! How are you today?":                       "Salut, omule! Ce mai faci azi?",
+		"The alarm is off.":                                      "Alarma este oprită.",
+		"The lights are off.":                                    "Luminile sunt stinse.",
+		"The lights are on at %d percent.":                       "Luminile sunt aprinse la %d la sută.",
+		"The %s lights are off.":                                 "Luminile din %s sunt stinse.",
+		"The %s lights are on at %d percent.":                    "Luminile din %s sunt aprinse la %d la sută.",
+		"The %s is off.":                                         "%s este stinsă.",
+		"The %s is on at %d percent.":                            "%s este aprinsă la %d la sută.",
+		"There are no lights.":                                   "Nu există lumini.",
+		"There are no rooms.":                                    "Nu există camere.",
+		"There are no scenes.":                                   "Nu există scene.",
+		"You have %d lights: %s.":                                "Ai %d lumini: %s.",
+		"You have %d rooms: %s.":                                 "Ai %d camere: %s.",
+		"You have %d scenes: %s.":                                "Ai %d scene: %s.",
+		"Timer set for %s.":                                      "Cronometrul este setat pentru %s.",
+		"OK, I will remind you at %s to %s.":                     "Bine, îți voi aminti la %s să %s.",
+		"Alarm set for %s.":                                      "Alarma este setată pentru %s.",
+		"You have no timers.":                                    "Nu ai niciun cronometru.",
+		"You have %d: %s.":                                       "Ai %d: %s.",
+		"There is nothing to cancel.":                            "Nu este nimic de anulat.",
+		"I cancelled %s.":                                        "Am anulat %s.",
+		"I cancelled %d timers.":                                 "Am anulat %d cronometre.",
+		"I don't know any commands about %s. I know about %s.":   "Nu știu nicio comandă despre %s. Știu despre %s.",
+		"I know %d commands about %s.":                           "Știu %d comenzi despre %s.",
+		"Do you want to hear them?":                              "Vrei să le auzi?",
+		"For %s, you can say: %s.":                               "Pentru %s, poți spune: %s.",
+		"%s, and %s":                                             "%s și %s",
+		"Should I continue with the rest of the command?":        "Să continui cu restul comenzii?",
+		"a reminder at %s to %s":                                 "un memento la %s să %s",
+		"an alarm at %s":                                         "o alarmă la %s",
+		"a timer of %s, ending at %s":                            "un cronometru de %s, care se termină la %s",
+		"Reminder: %s.":                                          "Memento: %s.",
+		"Wake up! It is %s.":                                     "Trezirea! Este ora %s.",
+		"Your %s timer is done.":                                 "Cronometrul de %s s-a terminat.",
+		"This was due at %s, while I was offline.":               "Trebuia să fie la %s, cât timp eram oprit.",
+		"Sentry mode activated!":                                 "Modul santinelă este pornit!",
+		"Sentry mode turned off!":                                "Modul santinelă este oprit!",
+		"Intruder detected! Sound the alarm!":                    "Intrus detectat! Porniți alarma!",
+	})
+}
@@ -0,0 +1,272 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package lights
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/normalize"
+)
+
+//AlarmDefinition holds the configuration of a light alarm pattern
+type AlarmDefinition struct {
+	Name string `json:"name"`
+	//Type is "flash", which goes through the colors, "strobe", which turns the first color on and off,
+	//or "pulse", which slowly brightens and dims the first color
+	Type   string   `json:"type"`
+	Colors []string `json:"colors,omitempty"`
+	//Interval is the time between two changes of the lights, such as "500ms"
+	Interval string `json:"interval,omitempty"`
+	//Duration is how long the alarm lasts, such as "16 seconds"
+	Duration string `json:"duration,omitempty"`
+}
+
+//DefaultAlarms are used when the configuration has no alarms
+var DefaultAlarms = []AlarmDefinition{
+	{Name: "alarm", Type: "flash", Colors: []string{"red"}},
+}
+
+// defaultAlarmDuration is how long the alarms last when their duration is not set
+const defaultAlarmDuration = 16 * time.Second
+
+// defaultIntervals are the intervals of each type of pattern, when not set.
+// The hue bridge handles about one change of a group per second, so none is shorter.
+var defaultIntervals = map[string]time.Duration{
+	"flash":  time.Second,
+	"strobe": time.Second,
+	"pulse":  2 * time.Second,
+}
+
+// defaultColors are the colors of each type of pattern, when not set
+var defaultColors = map[string]string{
+	"flash":  "red",
+	"strobe": "white",
+	"pulse":  "red",
+}
+
+//Pattern is a light alarm pattern, built from its definition
+type Pattern struct {
+	Name     string
+	steps    []Change
+	interval time.Duration
+	duration time.Duration
+}
+
+func (d AlarmDefinition) build() (*Pattern, error) {
+	res := &Pattern{Name: strings.ToLower(strings.TrimSpace(d.Name))}
+	if res.Name == "" {
+		return nil, fmt.Errorf("the alarm name is missing")
+	}
+
+	kind := strings.ToLower(d.Type)
+	res.interval = defaultIntervals[kind]
+	if res.interval == 0 {
+		return nil, fmt.Errorf("the alarm %s must be a flash, strobe, or pulse, not %q", res.Name, d.Type)
+	}
+	if d.Interval != "" {
+		var err error
+		if res.interval, err = normalize.Duration(d.Interval); err != nil {
+			return nil, fmt.Errorf("invalid interval for the alarm %s: %w", res.Name, err)
+		}
+		// The lights change in steps of 100ms at best
+		if res.interval < 100*time.Millisecond {
+			return nil, fmt.Errorf("the interval of the alarm %s must be at least 100ms", res.Name)
+		}
+	}
+	res.duration = defaultAlarmDuration
+	if d.Duration != "" {
+		var err error
+		if res.duration, err = normalize.Duration(d.Duration); err != nil {
+			return nil, fmt.Errorf("invalid duration for the alarm %s: %w", res.Name, err)
+		}
+	}
+
+	colors := d.Colors
+	if len(colors) == 0 {
+		colors = []string{defaultColors[kind]}
+	}
+	var changes []Change
+	for _, color := range colors {
+		if _, err := LookupColor(color); err != nil {
+			return nil, fmt.Errorf("invalid color for the alarm %s: %w", res.Name, err)
+		}
+		changes = append(changes, Change{Brightness: 254, Color: color, Transition: 100 * time.Millisecond})
+	}
+
+	off := Change{Off: true, Transition: 100 * time.Millisecond}
+	switch kind {
+	case "flash":
+		res.steps = changes
+		// A single color flashes on and off
+		if len(changes) == 1 {
+			res.steps = append(res.steps, off)
+		}
+	case "strobe":
+		res.steps = []Change{changes[0], off}
+	case "pulse":
+		bright, dim := changes[0], changes[0]
+		bright.Transition = res.interval
+		dim.Transition = res.interval
+		dim.Brightness = 25
+		res.steps = []Change{bright, dim}
+	}
+
+	return res, nil
+}
+
+//BuildAlarms creates the patterns from their definitions, or returns the first invalid one
+func BuildAlarms(defs []AlarmDefinition) ([]*Pattern, error) {
+	if len(defs) == 0 {
+		defs = DefaultAlarms
+	}
+
+	res := make([]*Pattern, 0, len(defs))
+	seen := map[string]bool{}
+	for _, d := range defs {
+		p, err := d.build()
+		if err != nil {
+			return nil, err
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("the alarm %s is defined more than once", p.Name)
+		}
+		seen[p.Name] = true
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+//ReplaceAlarms sets the alarm patterns which can be used. The first one is the default.
+func (s *Service) ReplaceAlarms(patterns []*Pattern) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patterns = patterns
+}
+
+// pattern finds the alarm pattern by name. An empty name means the default one.
+func (s *Service) pattern(name string) (*Pattern, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range s.patterns {
+		if name == "" || p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no alarm called %s", name)
+}
+
+//Alarm starts the alarm pattern on the light or group with the given name, in the background.
+//An empty name means all the lights, and an empty pattern means the default one.
+//It lasts the duration of the pattern, or until the context is done or StopAlarm is called,
+//and the lights are put back in their previous state either way. Only one alarm runs at a time.
+func (s *Service) Alarm(ctx context.Context, name, pattern string) error {
+	if len(s.backends) == 0 {
+		return nil
+	}
+
+	// The alarm is claimed under the lock, but the lights are only asked for their state once it is released,
+	// so StopAlarm doesn't wait for a slow bridge or broker
+	s.mu.Lock()
+	if s.stopAlarm != nil {
+		// The lights already show an alarm, so their state is already saved
+		s.mu.Unlock()
+		return nil
+	}
+	p, err := s.pattern(pattern)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	alarmCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	s.stopAlarm, s.alarmDone = cancel, done
+	s.mu.Unlock()
+
+	release := func() {
+		cancel()
+		s.mu.Lock()
+		s.stopAlarm, s.alarmDone = nil, nil
+		s.mu.Unlock()
+		close(done)
+	}
+
+	b, device, err := s.resolve(alarmCtx, name)
+	if err != nil {
+		release()
+		return err
+	}
+	restore, err := s.snapshot(alarmCtx, b, device)
+	if err != nil {
+		release()
+		return err
+	}
+
+	go func() {
+		defer release()
+
+		runCtx, cancelRun := context.WithTimeout(alarmCtx, p.duration)
+		err := s.run(runCtx, p, b, device)
+		cancelRun()
+		if err != nil {
+			log.Printf("the alarm %s failed: %v\n", p.Name, err)
+		}
+
+		// The alarm is over by now, and the lights are restored even when it was stopped early
+		restoreCtx, cancelRestore := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelRestore()
+		if err := restore(restoreCtx); err != nil {
+			log.Printf("failed to restore the lights after the alarm %s: %v\n", p.Name, err)
+		}
+	}()
+
+	return nil
+}
+
+//StopAlarm stops the running alarm, if any, and waits for the lights to be put back in their previous state
+func (s *Service) StopAlarm() {
+	s.mu.Lock()
+	stop, done := s.stopAlarm, s.alarmDone
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	stop()
+	<-done
+}
+
+// run changes the lights at every interval, until the context is done
+func (s *Service) run(ctx context.Context, p *Pattern, b Backend, device string) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for idx := 0; ; idx++ {
+		if _, err := s.set(ctx, b, device, p.steps[idx%len(p.steps)]); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,148 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package lights
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend records the changes of its lights. Its snapshots wait for the context while slow is set.
+type fakeBackend struct {
+	slow   bool
+	saving chan struct{}
+
+	mu       sync.Mutex
+	changes  []Change
+	restored int
+}
+
+func (f *fakeBackend) Name() string { return "fake" }
+
+func (f *fakeBackend) Devices(context.Context) ([]Device, error) {
+	return []Device{{Name: "kitchen", Group: true, Backend: "fake"}}, nil
+}
+
+func (f *fakeBackend) Set(_ context.Context, name string, change Change) (*State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.changes = append(f.changes, change)
+	return &State{Name: name, On: !change.Off}, nil
+}
+
+func (f *fakeBackend) Snapshot(ctx context.Context, _ string) (func(context.Context) error, error) {
+	if f.slow {
+		close(f.saving)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return func(context.Context) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.restored++
+		return nil
+	}, nil
+}
+
+func TestBuildAlarms(t *testing.T) {
+	patterns, err := BuildAlarms([]AlarmDefinition{
+		{Name: "Strobe", Type: "strobe"},
+		{Name: "police", Type: "flash", Colors: []string{"red", "blue"}, Interval: "2s", Duration: "30 seconds"},
+	})
+	if err != nil {
+		t.Fatalf("BuildAlarms failed: %v", err)
+	}
+	if p := patterns[0]; p.Name != "strobe" || p.interval != time.Second || p.duration != defaultAlarmDuration || len(p.steps) != 2 {
+		t.Errorf("got the strobe %+v", p)
+	}
+	if p := patterns[1]; p.interval != 2*time.Second || p.duration != 30*time.Second || len(p.steps) != 2 {
+		t.Errorf("got the police %+v", p)
+	}
+
+	for _, defs := range [][]AlarmDefinition{
+		{{Type: "flash"}},
+		{{Name: "disco", Type: "disco"}},
+		{{Name: "fast", Type: "strobe", Interval: "50ms"}},
+		{{Name: "pink", Type: "flash", Colors: []string{"not a color"}}},
+		{{Name: "alarm", Type: "flash"}, {Name: "Alarm", Type: "pulse"}},
+	} {
+		if _, err := BuildAlarms(defs); err == nil {
+			t.Errorf("BuildAlarms(%+v) succeeded, want an error", defs)
+		}
+	}
+}
+
+func TestAlarm(t *testing.T) {
+	fake := &fakeBackend{}
+	s := New(fake)
+	patterns, err := BuildAlarms([]AlarmDefinition{{Name: "alarm", Type: "flash", Colors: []string{"red"}, Interval: "100ms"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ReplaceAlarms(patterns)
+
+	if err := s.Alarm(context.Background(), "kitchen", ""); err != nil {
+		t.Fatalf("Alarm failed: %v", err)
+	}
+	// A second alarm doesn't replace the running one
+	if err := s.Alarm(context.Background(), "kitchen", ""); err != nil {
+		t.Fatalf("the second Alarm failed: %v", err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	s.StopAlarm()
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.changes) < 2 || fake.changes[0].Color != "red" || !fake.changes[1].Off {
+		t.Errorf("got the changes %+v, want red and off", fake.changes)
+	}
+	if fake.restored != 1 {
+		t.Errorf("the lights were restored %d times, want once", fake.restored)
+	}
+}
+
+func TestStopAlarmWhileSaving(t *testing.T) {
+	fake := &fakeBackend{slow: true, saving: make(chan struct{})}
+	s := New(fake)
+
+	started := make(chan error, 1)
+	go func() {
+		started <- s.Alarm(context.Background(), "kitchen", "")
+	}()
+	<-fake.saving
+
+	stopped := make(chan struct{})
+	go func() {
+		s.StopAlarm()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("StopAlarm waited for the lights to be saved")
+	}
+	if err := <-started; err == nil {
+		t.Error("Alarm succeeded, want the error of the snapshot")
+	}
+
+	// The stopped alarm no longer blocks the next one
+	fake.slow = false
+	if err := s.Alarm(context.Background(), "kitchen", ""); err != nil {
+		t.Fatalf("Alarm failed: %v", err)
+	}
+	s.StopAlarm()
+}
@@ -0,0 +1,108 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package lights
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+//Color is a named color, in the forms used by the backends
+type Color struct {
+	//Kelvin is the temperature of the whites, and 0 for the other colors
+	Kelvin int
+	//XY are the CIE coordinates of the color, and nil for the whites
+	XY  []float32
+	RGB [3]uint8
+}
+
+// whites are the color temperatures of the named whites, in kelvin
+var whites = map[string]int{
+	"candle light":  2000,
+	"warm white":    2200,
+	"soft white":    2700,
+	"white":         4000,
+	"neutral white": 4000,
+	"cool white":    5000,
+	"daylight":      6500,
+}
+
+// colors are the named colors
+var colors = map[string]Color{
+	"red":     {XY: []float32{0.675, 0.322}, RGB: [3]uint8{255, 0, 0}},
+	"orange":  {XY: []float32{0.5614, 0.4156}, RGB: [3]uint8{255, 100, 0}},
+	"yellow":  {XY: []float32{0.4325, 0.5007}, RGB: [3]uint8{255, 200, 0}},
+	"green":   {XY: []float32{0.4091, 0.518}, RGB: [3]uint8{0, 255, 0}},
+	"cyan":    {XY: []float32{0.1607, 0.3423}, RGB: [3]uint8{0, 255, 255}},
+	"blue":    {XY: []float32{0.167, 0.04}, RGB: [3]uint8{0, 0, 255}},
+	"purple":  {XY: []float32{0.2725, 0.1096}, RGB: [3]uint8{128, 0, 255}},
+	"magenta": {XY: []float32{0.3833, 0.1591}, RGB: [3]uint8{255, 0, 255}},
+	"pink":    {XY: []float32{0.3944, 0.3093}, RGB: [3]uint8{255, 105, 180}},
+}
+
+//LookupColor returns the named color, such as "red" or "warm white"
+func LookupColor(name string) (Color, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if kelvin, ok := whites[name]; ok {
+		return Color{Kelvin: kelvin, RGB: KelvinRGB(kelvin)}, nil
+	}
+	if c, ok := colors[name]; ok {
+		return c, nil
+	}
+	return Color{}, fmt.Errorf("there is no color called %s", name)
+}
+
+//Mired converts the color temperature to the unit used by the hue and zigbee lights, between 153 and 500.
+//The temperatures which are not positive are the warmest.
+func Mired(kelvin int) uint16 {
+	if kelvin <= 0 {
+		return 500
+	}
+	res := 1000000 / kelvin
+	switch {
+	case res < 153:
+		res = 153
+	case res > 500:
+		res = 500
+	}
+	return uint16(res)
+}
+
+//KelvinRGB approximates the color temperature for the lights which only have RGB leds
+func KelvinRGB(kelvin int) [3]uint8 {
+	t := float64(kelvin) / 100
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	return [3]uint8{channel(r), channel(g), channel(b)}
+}
+
+func channel(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
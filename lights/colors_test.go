@@ -0,0 +1,37 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package lights
+
+import "testing"
+
+func TestMired(t *testing.T) {
+	tests := []struct {
+		kelvin int
+		want   uint16
+	}{
+		{kelvin: 2700, want: 370},
+		{kelvin: 6500, want: 153},
+		{kelvin: 10000, want: 153},
+		{kelvin: 1000, want: 500},
+		{kelvin: 0, want: 500},
+		{kelvin: -2700, want: 500},
+	}
+
+	for _, tt := range tests {
+		if got := Mired(tt.kelvin); got != tt.want {
+			t.Errorf("Mired(%d) = %d, want %d", tt.kelvin, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package lights
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ErrNotConfigured is returned when no lights are configured
+var ErrNotConfigured = errors.New("no lights are configured")
+
+//Change describes how to change a light or a group of lights.
+//Unless Off is set, the lights are turned on, and the zero values are left unchanged.
+type Change struct {
+	Off bool
+	//Brightness is between 1 and 254
+	Brightness int
+	//Kelvin is the color temperature, between 2000 and 6500
+	Kelvin int
+	//Color is a named color, such as "red" or "warm white"
+	Color string
+	//Transition is how long the change takes. Each backend has its own default.
+	Transition time.Duration
+}
+
+//State is the state of a light or a group of lights, as reported by its backend
+type State struct {
+	Name string
+	//On tells if the light, or any light of the group, is on
+	On bool
+	//Brightness is between 0 and 100 percent
+	Brightness int
+	//All tells if the state is of all the lights
+	All bool
+	//Light tells if the state is of a single light, rather than a group
+	Light bool
+}
+
+//Device is a light or a group of lights, such as a room
+type Device struct {
+	Name    string
+	Group   bool
+	Backend string
+}
+
+//Backend controls the lights of a vendor, such as the hue bridge or the WLED strips
+type Backend interface {
+	//Name of the backend, such as "hue"
+	Name() string
+	//Devices returns the lights and groups of lights of the backend
+	Devices(ctx context.Context) ([]Device, error)
+	//Set changes the device with the given name, and returns its state after the change.
+	//An empty name means all the lights of the backend.
+	Set(ctx context.Context, name string, change Change) (*State, error)
+	//Snapshot saves the state of the device with the given name, and returns a function which restores it.
+	//An empty name means all the lights of the backend.
+	Snapshot(ctx context.Context, name string) (func(context.Context) error, error)
+}
+
+//Service addresses the lights of all the backends by name
+type Service struct {
+	backends []Backend
+
+	mu       sync.Mutex
+	patterns []*Pattern
+	// stopAlarm and alarmDone are set while an alarm runs
+	stopAlarm context.CancelFunc
+	alarmDone chan struct{}
+}
+
+//New creates a new lights Service
+func New(backends ...Backend) *Service {
+	res := &Service{backends: backends}
+	res.patterns, _ = BuildAlarms(DefaultAlarms)
+	return res
+}
+
+// allLights are the names which mean all the lights, besides the empty one
+var allLights = map[string]bool{
+	"lights":         true,
+	"all lights":     true,
+	"all the lights": true,
+	"everything":     true,
+}
+
+// resolve finds the backend of the device, and the name the backend knows it by.
+// A nil backend means all the lights.
+func (s *Service) resolve(ctx context.Context, name string) (Backend, string, error) {
+	if len(s.backends) == 0 {
+		return nil, "", ErrNotConfigured
+	}
+	name = strings.TrimSpace(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "the "))
+	if name == "" || allLights[name] {
+		return nil, "", nil
+	}
+
+	// "the kitchen lights" is the kitchen group
+	short := strings.TrimSpace(strings.TrimSuffix(name, " lights"))
+	var listErr error
+	for _, n := range []string{name, short} {
+		for _, b := range s.backends {
+			devices, err := b.Devices(ctx)
+			if err != nil {
+				if listErr == nil {
+					listErr = fmt.Errorf("failed to list the %s lights: %w", b.Name(), err)
+				}
+				continue
+			}
+			for _, d := range devices {
+				if strings.EqualFold(d.Name, n) {
+					return b, d.Name, nil
+				}
+			}
+		}
+	}
+
+	if listErr != nil {
+		return nil, "", listErr
+	}
+	return nil, "", fmt.Errorf("there is no room or light called %s", name)
+}
+
+//Devices returns the lights and groups of lights of all the backends, sorted by name
+func (s *Service) Devices(ctx context.Context) ([]Device, error) {
+	var res []Device
+	for _, b := range s.backends {
+		devices, err := b.Devices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the %s lights: %w", b.Name(), err)
+		}
+		res = append(res, devices...)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+//Set changes the light or the group, such as a room, with the given name, whatever its backend.
+//An empty name means all the lights. It returns the state after the change.
+func (s *Service) Set(ctx context.Context, name string, change Change) (*State, error) {
+	if err := change.check(); err != nil {
+		return nil, err
+	}
+	b, device, err := s.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.set(ctx, b, device, change)
+}
+
+func (s *Service) set(ctx context.Context, b Backend, device string, change Change) (*State, error) {
+	if b != nil {
+		return b.Set(ctx, device, change)
+	}
+
+	// All the lights are changed, even when a backend fails
+	var states []*State
+	var firstErr error
+	for _, b := range s.backends {
+		st, err := b.Set(ctx, "", change)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to change the %s lights: %w", b.Name(), err)
+			}
+			continue
+		}
+		states = append(states, st)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return Combine(states), nil
+}
+
+// snapshot saves the state of the device, or of all the lights for a nil backend
+func (s *Service) snapshot(ctx context.Context, b Backend, device string) (func(context.Context) error, error) {
+	if b != nil {
+		return b.Snapshot(ctx, device)
+	}
+
+	var restores []func(context.Context) error
+	for _, b := range s.backends {
+		restore, err := b.Snapshot(ctx, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to save the state of the %s lights: %w", b.Name(), err)
+		}
+		restores = append(restores, restore)
+	}
+	return func(ctx context.Context) error {
+		var firstErr error
+		for _, restore := range restores {
+			if err := restore(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}, nil
+}
+
+//Combine returns the state of all the lights, from the state of each light or group
+func Combine(states []*State) *State {
+	res := &State{All: true}
+	for _, st := range states {
+		if st.On {
+			res.On = true
+			if st.Brightness > res.Brightness {
+				res.Brightness = st.Brightness
+			}
+		}
+	}
+	return res
+}
+
+//TurnOn turns the lights on
+func (s *Service) TurnOn(ctx context.Context, name string) (*State, error) {
+	return s.Set(ctx, name, Change{})
+}
+
+//TurnOff turns the lights off
+func (s *Service) TurnOff(ctx context.Context, name string) (*State, error) {
+	return s.Set(ctx, name, Change{Off: true})
+}
+
+//SetBrightness turns the lights off when the brightness is 0, or on at the brightness, up to 255
+func (s *Service) SetBrightness(ctx context.Context, name string, brightness int) (*State, error) {
+	return s.Set(ctx, name, Change{Off: brightness <= 0, Brightness: brightness})
+}
+
+//SetTemperature turns the lights on at the color temperature, in kelvin
+func (s *Service) SetTemperature(ctx context.Context, name string, kelvin int) (*State, error) {
+	return s.Set(ctx, name, Change{Kelvin: kelvin})
+}
+
+//SetColor turns the lights on at the named color, such as "red" or "warm white"
+func (s *Service) SetColor(ctx context.Context, name, color string) (*State, error) {
+	return s.Set(ctx, name, Change{Color: color})
+}
+
+// check validates the change before any backend is called
+func (c Change) check() error {
+	if c.Color != "" {
+		if _, err := LookupColor(c.Color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Bri returns the brightness of the change between 1 and 254, or 0 when it's unchanged
+func (c Change) Bri() uint8 {
+	switch {
+	case c.Brightness <= 0:
+		return 0
+	case c.Brightness > 254:
+		return 254
+	}
+	return uint8(c.Brightness)
+}
@@ -0,0 +1,250 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// The MQTT 3.1.1 packet types used by PHAS
+const (
+	packetConnect     = 1
+	packetConnAck     = 2
+	packetPublish     = 3
+	packetSubscribe   = 8
+	packetSubAck      = 9
+	packetUnsubscribe = 10
+	packetUnsubAck    = 11
+	packetPingReq     = 12
+	packetPingResp    = 13
+	packetDisconnect  = 14
+)
+
+// flagRetain is set on the messages which the broker kept from before the subscription
+const flagRetain = 0x01
+
+// defaultTimeout limits the exchanges whose context has no deadline
+const defaultTimeout = 5 * time.Second
+
+// keepAlive is how long the broker keeps the connection without any packet from PHAS
+const keepAlive = 30 * time.Second
+
+// conn is an MQTT session, which only publishes and receives messages with QoS 0.
+// Its packets are read as they are expected, so it handles one exchange at a time.
+type conn struct {
+	c      net.Conn
+	r      *bufio.Reader
+	nextID uint16
+}
+
+// dial connects to the broker, and logs in when the username is set
+func dial(ctx context.Context, broker, clientID, username, password string) (*conn, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		c.Close()
+		return nil, err
+	}
+	res := &conn{c: c, r: bufio.NewReader(c)}
+
+	// Protocol name, level 4, the flags, and the keep alive in seconds
+	body := appendString(nil, "MQTT")
+	flags := byte(0x02) // clean session
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	seconds := int(keepAlive / time.Second)
+	body = append(body, 4, flags, byte(seconds>>8), byte(seconds))
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+		if password != "" {
+			body = appendString(body, password)
+		}
+	}
+	if err := res.write(packetConnect<<4, body); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	kind, _, payload, err := res.read()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if kind != packetConnAck || len(payload) != 2 {
+		c.Close()
+		return nil, fmt.Errorf("unexpected answer from the MQTT broker")
+	}
+	if payload[1] != 0 {
+		c.Close()
+		return nil, fmt.Errorf("the MQTT broker refused the connection with code %d", payload[1])
+	}
+	return res, nil
+}
+
+func (c *conn) close() error {
+	_ = c.write(packetDisconnect<<4, nil)
+	return c.c.Close()
+}
+
+func (c *conn) publish(topic string, payload []byte) error {
+	return c.write(packetPublish<<4, append(appendString(nil, topic), payload...))
+}
+
+// subscribe subscribes to the topic and waits for the broker to accept it
+func (c *conn) subscribe(topic string) error {
+	c.nextID++
+	body := []byte{byte(c.nextID >> 8), byte(c.nextID)}
+	body = append(appendString(body, topic), 0)
+	if err := c.write(packetSubscribe<<4|0x02, body); err != nil {
+		return err
+	}
+
+	payload, err := c.wait(packetSubAck)
+	if err != nil {
+		return err
+	}
+	if len(payload) < 3 || payload[2] == 0x80 {
+		return fmt.Errorf("the MQTT broker refused the subscription to %s", topic)
+	}
+	return nil
+}
+
+// unsubscribe unsubscribes from the topic and waits for the broker to confirm it,
+// so that no message of the topic is left for the next exchange
+func (c *conn) unsubscribe(topic string) error {
+	c.nextID++
+	body := appendString([]byte{byte(c.nextID >> 8), byte(c.nextID)}, topic)
+	if err := c.write(packetUnsubscribe<<4|0x02, body); err != nil {
+		return err
+	}
+	_, err := c.wait(packetUnsubAck)
+	return err
+}
+
+// ping tells the broker that the connection is still used
+func (c *conn) ping() error {
+	if err := c.write(packetPingReq<<4, nil); err != nil {
+		return err
+	}
+	_, err := c.wait(packetPingResp)
+	return err
+}
+
+// wait skips the packets until the next one of the given type, and returns its content
+func (c *conn) wait(kind byte) ([]byte, error) {
+	for {
+		k, _, payload, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		if k == kind {
+			return payload, nil
+		}
+	}
+}
+
+// receive waits for the next message on the topic. The retained messages are skipped,
+// as they hold the state from before the exchange.
+func (c *conn) receive(topic string) ([]byte, error) {
+	for {
+		kind, flags, payload, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		if kind != packetPublish || flags&flagRetain != 0 || len(payload) < 2 {
+			continue
+		}
+		size := int(binary.BigEndian.Uint16(payload))
+		if len(payload) < 2+size {
+			return nil, fmt.Errorf("invalid message from the MQTT broker")
+		}
+		if string(payload[2:2+size]) == topic {
+			return payload[2+size:], nil
+		}
+	}
+}
+
+func (c *conn) write(header byte, body []byte) error {
+	packet := []byte{header}
+	// The remaining length uses 7 bits per byte, with the highest bit set when more bytes follow
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := c.c.Write(append(packet, body...))
+	return err
+}
+
+// read returns the type, the flags, and the content of the next packet
+func (c *conn) read() (byte, byte, []byte, error) {
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	size, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, 0, nil, errors.New("invalid packet length from the MQTT broker")
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		size += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	return header >> 4, header & 0x0f, payload, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
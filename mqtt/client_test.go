@@ -0,0 +1,161 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// packet encodes a packet whose body is shorter than 128 bytes
+func packet(header byte, body []byte) []byte {
+	return append([]byte{header, byte(len(body))}, body...)
+}
+
+func TestWriteRead(t *testing.T) {
+	for _, size := range []int{0, 1, 127, 128, 16383, 16384, 2097151, 2097152} {
+		client, server := net.Pipe()
+		w, r := &conn{c: client}, &conn{c: server, r: bufio.NewReader(server)}
+
+		body := bytes.Repeat([]byte{'x'}, size)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- w.write(packetPublish<<4|flagRetain, body)
+		}()
+
+		kind, flags, payload, err := r.read()
+		if err != nil {
+			t.Fatalf("read of %d bytes failed: %v", size, err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("write of %d bytes failed: %v", size, err)
+		}
+		if kind != packetPublish || flags != flagRetain || !bytes.Equal(payload, body) {
+			t.Errorf("got the packet %d with the flags %d and %d bytes, want %d bytes", kind, flags, len(payload), size)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		// The remaining length has more than 4 bytes
+		{packetPublish << 4, 0xff, 0xff, 0xff, 0xff, 0x01},
+		// The content is shorter than its length
+		{packetPublish << 4, 5, 'a', 'b'},
+		{packetPublish << 4},
+	} {
+		c := &conn{r: bufio.NewReader(bytes.NewReader(data))}
+		if _, _, _, err := c.read(); err == nil {
+			t.Errorf("read(%v) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestReceive(t *testing.T) {
+	var data []byte
+	data = append(data, packet(packetSubAck<<4, []byte{0, 1, 0})...)
+	data = append(data, packet(packetPublish<<4|flagRetain, append(appendString(nil, "zigbee2mqtt/lamp"), "old"...))...)
+	data = append(data, packet(packetPublish<<4, append(appendString(nil, "zigbee2mqtt/other"), "other"...))...)
+	data = append(data, packet(packetPublish<<4, append(appendString(nil, "zigbee2mqtt/lamp"), "new"...))...)
+
+	c := &conn{r: bufio.NewReader(bytes.NewReader(data))}
+	payload, err := c.receive("zigbee2mqtt/lamp")
+	if err != nil {
+		t.Fatalf("receive failed: %v", err)
+	}
+	if string(payload) != "new" {
+		t.Errorf("got %q, want the message which isn't retained", payload)
+	}
+
+	// The topic is longer than the message
+	c = &conn{r: bufio.NewReader(bytes.NewReader(packet(packetPublish<<4, []byte{0, 20, 'a'})))}
+	if _, err := c.receive("a"); err == nil {
+		t.Error("receive of an invalid message succeeded, want an error")
+	}
+}
+
+func TestDial(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		flags    byte
+		code     byte
+		err      string
+	}{
+		{name: "anonymous", flags: 0x02},
+		{name: "username", username: "phas", flags: 0x82},
+		{name: "password", username: "phas", password: "secret", flags: 0xc2},
+		{name: "refused", username: "phas", password: "wrong", flags: 0xc2, code: 5, err: "code 5"},
+	}
+
+	for _, tt := range tests {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies := make(chan []byte, 1)
+		go func(code byte) {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			server := &conn{c: c, r: bufio.NewReader(c)}
+			kind, _, body, err := server.read()
+			if err != nil || kind != packetConnect {
+				bodies <- nil
+				return
+			}
+			bodies <- body
+			_ = server.write(packetConnAck<<4, []byte{0, code})
+			_, _, _, _ = server.read()
+		}(tt.code)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		c, err := dial(ctx, ln.Addr().String(), "phas-test", tt.username, tt.password)
+		cancel()
+
+		want := append(appendString(nil, "MQTT"), 4, tt.flags, 0, 30)
+		want = appendString(want, "phas-test")
+		if tt.username != "" {
+			want = appendString(want, tt.username)
+		}
+		if tt.password != "" {
+			want = appendString(want, tt.password)
+		}
+		if got := <-bodies; !bytes.Equal(got, want) {
+			t.Errorf("%s: got the connect packet %v, want %v", tt.name, got, want)
+		}
+
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: got the error %v, want %q", tt.name, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: dial failed: %v", tt.name, err)
+		}
+		if c != nil {
+			c.close()
+		}
+		ln.Close()
+	}
+}
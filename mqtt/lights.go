@@ -0,0 +1,336 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlsniper/phas/lights"
+)
+
+//Config holds the MQTT broker, and the lights which it controls
+type Config struct {
+	//Broker is the address of the broker, such as "localhost:1883"
+	Broker   string   `json:"broker"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	Devices  []Device `json:"devices"`
+}
+
+//Device is a light which uses the zigbee2mqtt messages
+type Device struct {
+	Name string `json:"name"`
+	//Topic is where the light publishes its state, and Topic/set is where it receives the changes.
+	//It's "zigbee2mqtt/" followed by the name by default.
+	Topic string `json:"topic,omitempty"`
+}
+
+func (d Device) topic() string {
+	if d.Topic != "" {
+		return d.Topic
+	}
+	return "zigbee2mqtt/" + d.Name
+}
+
+//Service controls the lights through the MQTT broker, over a single connection
+type Service struct {
+	cfg      Config
+	clientID string
+
+	// mu is held during each exchange with the broker
+	mu   sync.Mutex
+	conn *conn
+}
+
+// message is the state of a light, in the zigbee2mqtt format
+type message struct {
+	State      string `json:"state,omitempty"`
+	Brightness int    `json:"brightness,omitempty"`
+	//ColorTemp is in mired
+	ColorTemp int    `json:"color_temp,omitempty"`
+	Color     *xy    `json:"color,omitempty"`
+	ColorMode string `json:"color_mode,omitempty"`
+	//Transition is in seconds
+	Transition float64 `json:"transition,omitempty"`
+}
+
+type xy struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+}
+
+//New creates a new MQTT Service
+func New(cfg Config) *Service {
+	// The broker closes the older session with the same ID, so each PHAS has its own
+	return &Service{cfg: cfg, clientID: "phas-" + strconv.FormatInt(time.Now().UnixNano(), 36)}
+}
+
+//Name of the backend
+func (s *Service) Name() string {
+	return "mqtt"
+}
+
+//Devices returns the MQTT lights from the configuration
+func (s *Service) Devices(context.Context) ([]lights.Device, error) {
+	res := make([]lights.Device, 0, len(s.cfg.Devices))
+	for _, d := range s.cfg.Devices {
+		res = append(res, lights.Device{Name: d.Name, Backend: s.Name()})
+	}
+	return res, nil
+}
+
+// find returns the light with the given name, or all of them for an empty name
+func (s *Service) find(name string) ([]Device, error) {
+	if name == "" {
+		return s.cfg.Devices, nil
+	}
+	for _, d := range s.cfg.Devices {
+		if strings.EqualFold(d.Name, name) {
+			return []Device{d}, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no MQTT light called %s", name)
+}
+
+// do runs the exchange on the connection to the broker, which is opened when needed.
+// A failed exchange closes the connection, and is tried once more on a new one when the old one was stale.
+func (s *Service) do(ctx context.Context, exchange func(c *conn) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		reused := s.conn != nil
+		if !reused {
+			c, err := dial(ctx, s.cfg.Broker, s.clientID, s.cfg.Username, s.cfg.Password)
+			if err != nil {
+				return fmt.Errorf("the MQTT broker is not available: %w", err)
+			}
+			s.conn = c
+			go s.keepAlive(c)
+		}
+
+		err := s.run(ctx, s.conn, exchange)
+		if err == nil {
+			return nil
+		}
+		s.conn.close()
+		s.conn = nil
+		if !reused || attempt > 0 || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// run runs the exchange until the deadline of the context, or until the context is done
+func (s *Service) run(ctx context.Context, c *conn, exchange func(c *conn) error) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	if err := c.c.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	done, watched := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(watched)
+		select {
+		case <-ctx.Done():
+			// Interrupts the reads and writes of the exchange
+			_ = c.c.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	err := exchange(c)
+	// The deadline must not be changed once the next exchange starts
+	close(done)
+	<-watched
+	return err
+}
+
+// keepAlive pings the broker while the connection is idle, until it's closed
+func (s *Service) keepAlive(c *conn) {
+	ticker := time.NewTicker(keepAlive / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		if s.conn != c {
+			s.mu.Unlock()
+			return
+		}
+		err := c.c.SetDeadline(time.Now().Add(defaultTimeout))
+		if err == nil {
+			err = c.ping()
+		}
+		if err != nil {
+			log.Printf("lost the connection to the MQTT broker: %v\n", err)
+			c.close()
+			s.conn = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// mqttMessage converts the change to the message sent to the lights
+func mqttMessage(c lights.Change) (*message, error) {
+	res := &message{State: "ON", Transition: c.Transition.Seconds()}
+	if c.Off {
+		res.State = "OFF"
+		return res, nil
+	}
+
+	res.Brightness = int(c.Bri())
+	if c.Kelvin > 0 {
+		res.ColorTemp = int(lights.Mired(c.Kelvin))
+	}
+	if c.Color != "" {
+		color, err := lights.LookupColor(c.Color)
+		if err != nil {
+			return nil, err
+		}
+		if color.Kelvin > 0 {
+			res.ColorTemp = int(lights.Mired(color.Kelvin))
+		} else {
+			res.Color = &xy{X: color.XY[0], Y: color.XY[1]}
+		}
+	}
+	return res, nil
+}
+
+//Set changes the MQTT light with the given name, or all of them for an empty name.
+//It returns the state which the lights publish after the change.
+func (s *Service) Set(ctx context.Context, name string, change lights.Change) (*lights.State, error) {
+	msg, err := mqttMessage(change)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*lights.State
+	for _, d := range devices {
+		reply, err := s.exchange(ctx, d, "/set", msg)
+		if err != nil {
+			return nil, err
+		}
+		st := &lights.State{Name: d.Name, On: reply.State == "ON", Light: true}
+		if st.On {
+			st.Brightness = (reply.Brightness*100 + 127) / 254
+		}
+		states = append(states, st)
+	}
+
+	if name == "" {
+		return lights.Combine(states), nil
+	}
+	return states[0], nil
+}
+
+// exchange publishes the message to the subtopic of the light, such as "/set", and waits for its new state
+func (s *Service) exchange(ctx context.Context, d Device, subtopic string, msg interface{}) (*message, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	topic := d.topic()
+	var b []byte
+	err = s.do(ctx, func(c *conn) error {
+		if err := c.subscribe(topic); err != nil {
+			return err
+		}
+		if err := c.publish(topic+subtopic, payload); err != nil {
+			return err
+		}
+		var err error
+		if b, err = c.receive(topic); err != nil {
+			return fmt.Errorf("the light %s didn't report its state: %w", d.Name, err)
+		}
+		return c.unsubscribe(topic)
+	})
+	if err != nil {
+		return nil, err
+	}
+	reply := &message{}
+	if err := json.Unmarshal(b, reply); err != nil {
+		return nil, fmt.Errorf("invalid state from the light %s: %w", d.Name, err)
+	}
+	return reply, nil
+}
+
+//Snapshot saves the state of the MQTT light with the given name, or of all of them for an empty name,
+//and returns a function which restores it
+func (s *Service) Snapshot(ctx context.Context, name string) (func(context.Context) error, error) {
+	devices, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make([]*message, len(devices))
+	for idx, d := range devices {
+		// zigbee2mqtt publishes the state of the light when asked for it
+		get := map[string]string{"state": "", "brightness": "", "color": "", "color_temp": ""}
+		if saved[idx], err = s.exchange(ctx, d, "/get", get); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(ctx context.Context) error {
+		return s.do(ctx, func(c *conn) error {
+			for idx, d := range devices {
+				if err := c.restore(d, saved[idx]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}, nil
+}
+
+// restore publishes the saved state of the light. The lights which were off get their color back first.
+func (c *conn) restore(d Device, saved *message) error {
+	msg := &message{State: "ON", Brightness: saved.Brightness}
+	if saved.ColorMode == "color_temp" {
+		msg.ColorTemp = saved.ColorTemp
+	} else {
+		msg.Color = saved.Color
+	}
+	messages := []*message{msg}
+	if saved.State != "ON" {
+		messages = append(messages, &message{State: "OFF"})
+	}
+
+	for _, m := range messages {
+		payload, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := c.publish(d.topic()+"/set", payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
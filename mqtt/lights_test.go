@@ -0,0 +1,236 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dlsniper/phas/lights"
+)
+
+// fakeBroker acts as the broker and as the zigbee2mqtt lights. It retains the state of each light,
+// and sends it to the new subscribers, as zigbee2mqtt does with retain: true.
+type fakeBroker struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	accepts int
+	conns   []net.Conn
+	states  map[string][]byte
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{ln: ln, states: map[string][]byte{}}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.accepts++
+			b.conns = append(b.conns, c)
+			b.mu.Unlock()
+			go b.serve(c)
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		b.drop()
+	})
+	return b
+}
+
+// drop closes the connections, as a restarted broker would
+func (b *fakeBroker) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+	b.conns = nil
+}
+
+func (b *fakeBroker) serve(nc net.Conn) {
+	defer nc.Close()
+	c := &conn{c: nc, r: bufio.NewReader(nc)}
+	subscribed := map[string]bool{}
+	for {
+		kind, _, body, err := c.read()
+		if err != nil {
+			return
+		}
+		switch kind {
+		case packetConnect:
+			err = c.write(packetConnAck<<4, []byte{0, 0})
+		case packetSubscribe:
+			topic := readString(body[2:])
+			subscribed[topic] = true
+			err = c.write(packetSubAck<<4, []byte{body[0], body[1], 0})
+			b.mu.Lock()
+			state := b.states[topic]
+			b.mu.Unlock()
+			if err == nil && state != nil {
+				err = c.write(packetPublish<<4|flagRetain, append(appendString(nil, topic), state...))
+			}
+		case packetUnsubscribe:
+			delete(subscribed, readString(body[2:]))
+			err = c.write(packetUnsubAck<<4, body[:2])
+		case packetPublish:
+			topic := readString(body)
+			payload := body[2+len(topic):]
+			light := topic[:strings.LastIndex(topic, "/")]
+			b.mu.Lock()
+			if strings.HasSuffix(topic, "/set") {
+				b.states[light] = payload
+			}
+			state := b.states[light]
+			b.mu.Unlock()
+			if subscribed[light] {
+				err = c.write(packetPublish<<4, append(appendString(nil, light), state...))
+			}
+		case packetPingReq:
+			err = c.write(packetPingResp<<4, nil)
+		case packetDisconnect:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func readString(b []byte) string {
+	size := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+size])
+}
+
+func (b *fakeBroker) state(topic string) *message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := &message{}
+	_ = json.Unmarshal(b.states[topic], res)
+	return res
+}
+
+func TestSet(t *testing.T) {
+	broker := newFakeBroker(t)
+	broker.states["zigbee2mqtt/lamp"] = []byte(`{"state": "OFF"}`)
+	s := New(Config{Broker: broker.ln.Addr().String(), Devices: []Device{{Name: "lamp"}, {Name: "desk", Topic: "home/desk"}}})
+	ctx := context.Background()
+
+	st, err := s.Set(ctx, "lamp", lights.Change{Brightness: 127})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	// The retained state is from before the change
+	if !st.On || st.Brightness != 50 {
+		t.Errorf("got the state %+v, want on at 50 percent", st)
+	}
+
+	st, err = s.Set(ctx, "", lights.Change{Off: true})
+	if err != nil {
+		t.Fatalf("Set of all the lights failed: %v", err)
+	}
+	if st.On || !st.All {
+		t.Errorf("got the state %+v, want all the lights off", st)
+	}
+	if got := broker.state("home/desk"); got.State != "OFF" {
+		t.Errorf("got the desk state %+v, want off", got)
+	}
+
+	if _, err := s.Set(ctx, "kitchen", lights.Change{}); err == nil {
+		t.Error("Set of an unknown light succeeded, want an error")
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.accepts != 1 {
+		t.Errorf("the service connected %d times, want once", broker.accepts)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	broker := newFakeBroker(t)
+	broker.states["zigbee2mqtt/lamp"] = []byte(`{"state": "OFF", "brightness": 200, "color_mode": "color_temp", "color_temp": 370}`)
+	s := New(Config{Broker: broker.ln.Addr().String(), Devices: []Device{{Name: "lamp"}}})
+	ctx := context.Background()
+
+	restore, err := s.Snapshot(ctx, "lamp")
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if _, err := s.Set(ctx, "lamp", lights.Change{Color: "red"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := restore(ctx); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	// The restore doesn't wait for the state, so a last exchange makes sure it's done
+	if _, err := s.Snapshot(ctx, "lamp"); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if got := broker.state("zigbee2mqtt/lamp"); got.State != "OFF" {
+		t.Errorf("got the state %+v after the restore, want off", got)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	broker := newFakeBroker(t)
+	s := New(Config{Broker: broker.ln.Addr().String(), Devices: []Device{{Name: "lamp"}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := s.Set(ctx, "lamp", lights.Change{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	broker.drop()
+	if _, err := s.Set(ctx, "lamp", lights.Change{}); err != nil {
+		t.Fatalf("Set after the broker dropped the connection failed: %v", err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.accepts != 2 {
+		t.Errorf("the service connected %d times, want twice", broker.accepts)
+	}
+}
+
+func TestBrokerNotAvailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := New(Config{Broker: addr, Devices: []Device{{Name: "lamp"}}})
+	if _, err := s.Set(context.Background(), "lamp", lights.Change{}); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("got the error %v, want the broker to be not available", err)
+	}
+}
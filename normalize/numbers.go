@@ -0,0 +1,228 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+
+//Package normalize turns the spoken values found in the transcripts, such as "forty percent"
+//or "an hour and a half", into typed values.
+package normalize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ones = map[string]float64{
+	"zero": 0, "oh": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+	"eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15,
+	"sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19,
+	"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50,
+	"sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+}
+
+var scales = map[string]float64{
+	"hundred":  100,
+	"thousand": 1000,
+}
+
+var fractions = map[string]float64{
+	"half":     0.5,
+	"halves":   0.5,
+	"quarter":  0.25,
+	"quarters": 0.25,
+}
+
+var ordinals = map[string]string{
+	"first":   "one",
+	"second":  "two",
+	"third":   "three",
+	"fifth":   "five",
+	"eighth":  "eight",
+	"ninth":   "nine",
+	"twelfth": "twelve",
+}
+
+func words(text string) []string {
+	text = strings.NewReplacer("-", " ", ",", " ").Replace(strings.ToLower(text))
+	return strings.Fields(strings.Trim(text, " .!?"))
+}
+
+//parseWords parses numbers such as "a hundred and twenty", "forty five", "one and a half", or "three quarters"
+func parseWords(tokens []string) (float64, bool) {
+	if len(tokens) == 0 {
+		return 0, false
+	}
+
+	var total, current float64
+	// counted tells if current already has a number since the last "thousand"
+	var seen, counted, and, decimals bool
+	scale := 1.0
+	for idx, token := range tokens {
+		if decimals {
+			digit, ok := ones[token]
+			if !ok || digit > 9 {
+				return 0, false
+			}
+			scale /= 10
+			current += digit * scale
+			continue
+		}
+
+		if v, ok := ones[token]; ok {
+			if counted && !fits(current, v) {
+				return 0, false
+			}
+			current += v
+			seen, counted = true, true
+		} else if v, err := strconv.ParseFloat(token, 64); err == nil {
+			if counted && !fits(current, v) {
+				return 0, false
+			}
+			current += v
+			seen, counted = true, true
+		} else if v, ok := scales[token]; ok {
+			if current == 0 {
+				current = 1
+			}
+			if v == 1000 {
+				total += current * v
+				current = 0
+				counted = false
+			} else {
+				current *= v
+			}
+			seen = true
+		} else if v, ok := fractions[token]; ok {
+			if current > 0 && !and {
+				current *= v
+			} else {
+				current += v
+			}
+			seen = true
+		} else {
+			switch token {
+			case "and":
+				and = true
+				continue
+			case "a", "an":
+				// Only as in "a hundred" or "a half"
+				if idx+1 == len(tokens) {
+					return 0, false
+				}
+				if _, ok := scales[tokens[idx+1]]; !ok {
+					if _, ok := fractions[tokens[idx+1]]; !ok {
+						return 0, false
+					}
+				}
+				continue
+			case "point":
+				if !seen {
+					return 0, false
+				}
+				decimals = true
+				continue
+			default:
+				return 0, false
+			}
+		}
+		and = false
+	}
+
+	if !seen {
+		return 0, false
+	}
+	return total + current, true
+}
+
+// fits tells if the number can follow current, as in "hundred and five" or "twenty five",
+// unlike in "one two" or "5 0", which are not a single number
+func fits(current, v float64) bool {
+	if current == 0 || v < 0 || v != math.Trunc(v) {
+		return false
+	}
+	if math.Mod(current, 100) == 0 {
+		return v < 100
+	}
+	tens := math.Mod(current, 100)
+	return tens >= 20 && math.Mod(tens, 10) == 0 && v < 10
+}
+
+//Number parses numbers written with digits, such as "40" or "1.5", or spoken, such as "forty" or "a hundred"
+func Number(text string) (float64, error) {
+	if n, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+		return n, nil
+	}
+	n, ok := parseWords(words(text))
+	if !ok {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return n, nil
+}
+
+//Percent parses percentages such as "40%", "forty percent", or "half", and returns a value between 0 and 100
+func Percent(text string) (int, error) {
+	value := strings.TrimSpace(strings.ToLower(text))
+	for _, suffix := range []string{"%", "percent", "per cent"} {
+		value = strings.TrimSpace(strings.TrimSuffix(value, suffix))
+	}
+
+	var n float64
+	switch value {
+	case "full", "max", "maximum":
+		n = 100
+	case "half":
+		n = 50
+	default:
+		var err error
+		n, err = Number(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid percentage %q", text)
+		}
+	}
+
+	if n < 0 || n > 100 {
+		return 0, fmt.Errorf("the percentage must be between 0 and 100, not %q", text)
+	}
+	return int(math.Round(n)), nil
+}
+
+//Ordinal parses ordinal numbers such as "3rd", "third", or "twenty first"
+func Ordinal(text string) (int, error) {
+	tokens := words(text)
+	if len(tokens) == 0 {
+		return 0, fmt.Errorf("invalid ordinal %q", text)
+	}
+
+	last := tokens[len(tokens)-1]
+	switch {
+	case ordinals[last] != "":
+		last = ordinals[last]
+	case strings.HasSuffix(last, "ieth"):
+		last = strings.TrimSuffix(last, "ieth") + "y"
+	case len(last) > 2 && (strings.HasSuffix(last, "st") || strings.HasSuffix(last, "nd") ||
+		strings.HasSuffix(last, "rd") || strings.HasSuffix(last, "th")):
+		last = last[:len(last)-2]
+	default:
+		return 0, fmt.Errorf("invalid ordinal %q", text)
+	}
+	tokens[len(tokens)-1] = last
+
+	n, ok := parseWords(tokens)
+	if !ok || n < 1 || n != math.Trunc(n) {
+		return 0, fmt.Errorf("invalid ordinal %q", text)
+	}
+	return int(n), nil
+}
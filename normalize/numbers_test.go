@@ -0,0 +1,128 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package normalize
+
+import "testing"
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+		err  bool
+	}{
+		{text: "40", want: 40},
+		{text: "1.5", want: 1.5},
+		{text: "forty", want: 40},
+		{text: "Forty-five", want: 45},
+		{text: "a hundred", want: 100},
+		{text: "a hundred and twenty", want: 120},
+		{text: "one hundred and five", want: 105},
+		{text: "two thousand five hundred", want: 2500},
+		{text: "one and a half", want: 1.5},
+		{text: "three quarters", want: 0.75},
+		{text: "two point five", want: 2.5},
+		{text: "zero", want: 0},
+		{text: "", err: true},
+		{text: "lots", err: true},
+		{text: "one two", err: true},
+		{text: "5 0", err: true},
+		{text: "twenty thirty", err: true},
+		{text: "ten five", err: true},
+		{text: "forty five six", err: true},
+		{text: "a", err: true},
+		{text: "point five", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Number(tt.text)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Number(%q) = %v, want an error", tt.text, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Number(%q) = %v, %v, want %v", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+		err  bool
+	}{
+		{text: "40%", want: 40},
+		{text: "40 %", want: 40},
+		{text: "forty percent", want: 40},
+		{text: "seventy five per cent", want: 75},
+		{text: "half", want: 50},
+		{text: "Full", want: 100},
+		{text: "0", want: 0},
+		{text: "33.6", want: 34},
+		{text: "5 0", err: true},
+		{text: "one two", err: true},
+		{text: "120%", err: true},
+		{text: "-5", err: true},
+		{text: "bright", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Percent(tt.text)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Percent(%q) = %v, want an error", tt.text, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Percent(%q) = %v, %v, want %v", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+		err  bool
+	}{
+		{text: "3rd", want: 3},
+		{text: "first", want: 1},
+		{text: "third", want: 3},
+		{text: "twelfth", want: 12},
+		{text: "twentieth", want: 20},
+		{text: "twenty first", want: 21},
+		{text: "twenty-second", want: 22},
+		{text: "one hundredth", want: 100},
+		{text: "three", err: true},
+		{text: "", err: true},
+		{text: "one second", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Ordinal(tt.text)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Ordinal(%q) = %v, want an error", tt.text, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Ordinal(%q) = %v, %v, want %v", tt.text, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+
+package normalize
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var units = map[string]time.Duration{
+	"second":  time.Second,
+	"seconds": time.Second,
+	"sec":     time.Second,
+	"secs":    time.Second,
+	"minute":  time.Minute,
+	"minutes": time.Minute,
+	"min":     time.Minute,
+	"mins":    time.Minute,
+	"hour":    time.Hour,
+	"hours":   time.Hour,
+	"day":     24 * time.Hour,
+	"days":    24 * time.Hour,
+}
+
+//Duration parses durations such as "10 minutes", "an hour and a half", "half an hour",
+//or "1 hour and 30 minutes". Go durations such as "1h30m" are accepted too.
+func Duration(text string) (time.Duration, error) {
+	if d, err := time.ParseDuration(strings.TrimSpace(text)); err == nil && d > 0 {
+		return d, nil
+	}
+
+	var res, last time.Duration
+	var pending []string
+	for _, token := range words(text) {
+		unit, ok := units[token]
+		if !ok {
+			if token != "of" {
+				pending = append(pending, token)
+			}
+			continue
+		}
+
+		// "half an hour" and "a quarter of an hour" are fractions of "an hour"
+		if n := len(pending); n > 1 && (pending[n-1] == "a" || pending[n-1] == "an") {
+			pending = pending[:n-1]
+		}
+		if len(pending) > 0 && pending[0] == "and" {
+			pending = pending[1:]
+		}
+
+		var n float64
+		if len(pending) == 1 && (pending[0] == "a" || pending[0] == "an") {
+			n = 1
+		} else {
+			var ok bool
+			n, ok = parseWords(pending)
+			if !ok {
+				return 0, fmt.Errorf("invalid duration %q", text)
+			}
+		}
+		res += time.Duration(n * float64(unit))
+		last = unit
+		pending = nil
+	}
+
+	// "an hour and a half" ends with a fraction of the last unit
+	if len(pending) > 0 {
+		n, ok := parseWords(pending)
+		if !ok || last == 0 || n >= 1 {
+			return 0, fmt.Errorf("invalid duration %q", text)
+		}
+		res += time.Duration(n * float64(last))
+	}
+
+	if res <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", text)
+	}
+	return res, nil
+}
+
+var (
+	clockDigits   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?$`)
+	clockMeridiem = regexp.MustCompile(`^(.*?)\s*(am|pm)$`)
+	dayParts      = strings.NewReplacer(
+		"a.m.", "am",
+		"p.m.", "pm",
+		"in the morning", "am",
+		"in the afternoon", "pm",
+		"in the evening", "pm",
+		"at night", "pm",
+		"o'clock", "",
+		"oclock", "",
+	)
+)
+
+//Clock parses times of the day such as "6 pm", "18:30", "six thirty in the evening", "half past seven",
+//or "quarter to nine", and returns their next occurrence after now. Without am or pm, a spoken time
+//such as "half past seven" is the next of 7:30 and 19:30.
+func Clock(text string, now time.Time) (time.Time, error) {
+	hour, minute, ambiguous, err := timeOfDay(text)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if ambiguous {
+		// Start from the earlier of the two times, such as 7:30 for "half past seven" or 0:30 for "twelve thirty"
+		hour %= 12
+	}
+	res := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !res.After(now) && ambiguous {
+		res = time.Date(now.Year(), now.Month(), now.Day(), hour+12, minute, 0, 0, now.Location())
+	}
+	if !res.After(now) {
+		res = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, now.Location())
+	}
+	return res, nil
+}
+
+//TimeOfDay parses the same times as Clock and returns their hour and minute.
+//Without am or pm, the hour is taken as it was said, e.g. 7 for "half past seven".
+func TimeOfDay(text string) (int, int, error) {
+	hour, minute, _, err := timeOfDay(text)
+	return hour, minute, err
+}
+
+// timeOfDay returns the hour and minute of the time, and if it can also be the same time after noon
+func timeOfDay(text string) (int, int, bool, error) {
+	value := strings.TrimSpace(dayParts.Replace(strings.ToLower(text)))
+	value = strings.TrimPrefix(value, "at ")
+	switch value {
+	case "noon", "midday":
+		value = "12:00"
+	case "midnight":
+		value = "0:00"
+	}
+
+	var meridiem string
+	if found := clockMeridiem.FindStringSubmatch(value); found != nil {
+		value, meridiem = found[1], found[2]
+	}
+
+	hour, minute, err := clockTime(value)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("invalid time %q", text)
+	}
+	switch meridiem {
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, false, fmt.Errorf("invalid time %q", text)
+	}
+
+	// "18:30" and "07:30" use the 24 hours clock, while "seven thirty" may be in the evening.
+	// It depends on the hour as it was said, e.g. "one" for "quarter to one".
+	ambiguous := meridiem == "" && hour >= 1 && hour <= 12 && !strings.Contains(value, ":")
+	if minute < 0 {
+		hour, minute = (hour+23)%24, 60+minute
+	}
+	return hour, minute, ambiguous, nil
+}
+
+//clockTime returns the hour and minute of a time without the am or pm part.
+//For the times before the hour, such as "quarter to nine", the hour is the one said and the minutes are negative.
+func clockTime(value string) (int, int, error) {
+	if found := clockDigits.FindStringSubmatch(value); found != nil {
+		hour, _ := strconv.Atoi(found[1])
+		minute := 0
+		if found[2] != "" {
+			minute, _ = strconv.Atoi(found[2])
+		}
+		return hour, minute, nil
+	}
+
+	tokens := words(value)
+	for idx, token := range tokens {
+		if token != "past" && token != "to" {
+			continue
+		}
+		minute, err := clockMinutes(tokens[:idx])
+		if err != nil {
+			return 0, 0, err
+		}
+		hour, err := wholeNumber(tokens[idx+1:])
+		if err != nil {
+			return 0, 0, err
+		}
+		if token == "to" {
+			minute = -minute
+		}
+		return hour, minute, nil
+	}
+
+	// "six thirty" or "seven oh five"
+	if len(tokens) == 0 {
+		return 0, 0, fmt.Errorf("missing time")
+	}
+	hour, err := wholeNumber(tokens[:1])
+	if err != nil {
+		return 0, 0, err
+	}
+	minute := 0
+	if len(tokens) > 1 {
+		minute, err = wholeNumber(tokens[1:])
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return hour, minute, nil
+}
+
+//clockMinutes parses the minutes before "past" or "to", such as "half", "a quarter", or "ten minutes"
+func clockMinutes(tokens []string) (int, error) {
+	if n := len(tokens); n > 0 && (tokens[n-1] == "minutes" || tokens[n-1] == "minute") {
+		tokens = tokens[:n-1]
+	}
+	switch strings.Join(tokens, " ") {
+	case "half":
+		return 30, nil
+	case "quarter", "a quarter":
+		return 15, nil
+	}
+	minute, err := wholeNumber(tokens)
+	if err != nil || minute < 1 || minute > 59 {
+		return 0, fmt.Errorf("invalid minutes")
+	}
+	return minute, nil
+}
+
+func wholeNumber(tokens []string) (int, error) {
+	if len(tokens) > 0 && tokens[0] == "oh" {
+		tokens = tokens[1:]
+	}
+	n, ok := parseWords(tokens)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return 0, fmt.Errorf("invalid number %q", strings.Join(tokens, " "))
+	}
+	return int(n), nil
+}
@@ -0,0 +1,139 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package normalize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+		err  bool
+	}{
+		{text: "1h30m", want: 90 * time.Minute},
+		{text: "10 minutes", want: 10 * time.Minute},
+		{text: "ten minutes", want: 10 * time.Minute},
+		{text: "a minute", want: time.Minute},
+		{text: "an hour and a half", want: 90 * time.Minute},
+		{text: "half an hour", want: 30 * time.Minute},
+		{text: "a quarter of an hour", want: 15 * time.Minute},
+		{text: "1 hour and 30 minutes", want: 90 * time.Minute},
+		{text: "two days", want: 48 * time.Hour},
+		{text: "thirty seconds", want: 30 * time.Second},
+		{text: "", err: true},
+		{text: "0s", err: true},
+		{text: "soon", err: true},
+		{text: "one two minutes", err: true},
+		{text: "an hour and two", err: true},
+		{text: "a half", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Duration(tt.text)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Duration(%q) = %v, want an error", tt.text, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Duration(%q) = %v, %v, want %v", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2021, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	morning, evening := at(1, 10, 0), at(1, 21, 0)
+
+	tests := []struct {
+		text string
+		now  time.Time
+		want time.Time
+		err  bool
+	}{
+		{text: "18:30", now: morning, want: at(1, 18, 30)},
+		{text: "07:30", now: morning, want: at(2, 7, 30)},
+		{text: "7:30", now: morning, want: at(2, 7, 30)},
+		{text: "6 pm", now: morning, want: at(1, 18, 0)},
+		{text: "6 p.m.", now: evening, want: at(2, 18, 0)},
+		{text: "11 am", now: morning, want: at(1, 11, 0)},
+		{text: "12 am", now: morning, want: at(2, 0, 0)},
+		{text: "12 pm", now: morning, want: at(1, 12, 0)},
+		{text: "at six thirty in the evening", now: morning, want: at(1, 18, 30)},
+		{text: "seven oh five in the morning", now: morning, want: at(2, 7, 5)},
+		{text: "noon", now: morning, want: at(1, 12, 0)},
+		{text: "midnight", now: morning, want: at(2, 0, 0)},
+		{text: "nine o'clock", now: morning, want: at(1, 21, 0)},
+		// Without am or pm, a spoken time is the next of the morning and the evening one
+		{text: "half past seven", now: morning, want: at(1, 19, 30)},
+		{text: "half past seven", now: at(1, 6, 0), want: at(1, 7, 30)},
+		{text: "half past seven", now: evening, want: at(2, 7, 30)},
+		{text: "quarter to nine", now: morning, want: at(1, 20, 45)},
+		{text: "ten past eleven", now: morning, want: at(1, 11, 10)},
+		// The hour said for "quarter to one" is one, so it's also ambiguous
+		{text: "quarter to one", now: at(1, 12, 10), want: at(1, 12, 45)},
+		{text: "quarter to one", now: at(1, 13, 0), want: at(2, 0, 45)},
+		{text: "quarter to one pm", now: morning, want: at(1, 12, 45)},
+		{text: "quarter to one am", now: morning, want: at(2, 0, 45)},
+		{text: "ten to twelve pm", now: morning, want: at(1, 11, 50)},
+		{text: "twelve thirty", now: morning, want: at(1, 12, 30)},
+		{text: "twelve thirty", now: evening, want: at(2, 0, 30)},
+		{text: "7", now: morning, want: at(1, 19, 0)},
+		{text: "25:00", now: morning, err: true},
+		{text: "half past", now: morning, err: true},
+		{text: "sixty past seven", now: morning, err: true},
+		{text: "later", now: morning, err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Clock(tt.text, tt.now)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Clock(%q) = %v, want an error", tt.text, got)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("Clock(%q) at %v = %v, %v, want %v", tt.text, tt.now, got, err, tt.want)
+		}
+	}
+}
+
+func TestTimeOfDay(t *testing.T) {
+	tests := []struct {
+		text         string
+		hour, minute int
+	}{
+		{text: "half past seven", hour: 7, minute: 30},
+		{text: "half past six pm", hour: 18, minute: 30},
+		{text: "22:15", hour: 22, minute: 15},
+		{text: "quarter to twelve", hour: 11, minute: 45},
+		{text: "quarter to one", hour: 0, minute: 45},
+		{text: "quarter to one pm", hour: 12, minute: 45},
+	}
+
+	for _, tt := range tests {
+		hour, minute, err := TimeOfDay(tt.text)
+		if err != nil || hour != tt.hour || minute != tt.minute {
+			t.Errorf("TimeOfDay(%q) = %d:%02d, %v, want %d:%02d", tt.text, hour, minute, err, tt.hour, tt.minute)
+		}
+	}
+}
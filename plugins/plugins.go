@@ -0,0 +1,282 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+
+//Package plugins runs intents implemented by external executables.
+//Each message to a plugin starts the executable, writes the Message as JSON to its standard input,
+//and reads the answer as JSON from its standard output.
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/normalize"
+	"github.com/dlsniper/phas/tts"
+)
+
+//ActionName is the name of the action which runs the intents of the plugins
+const ActionName = "plugin"
+
+// defaultTimeout limits how long a plugin can take to answer
+const defaultTimeout = 30 * time.Second
+
+//Definition describes a plugin in the configuration file
+type Definition struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Timeout string   `json:"timeout,omitempty"`
+}
+
+type plugin struct {
+	Definition
+	timeout time.Duration
+}
+
+//Manager keeps track of the configured plugins
+type Manager struct {
+	mu      sync.RWMutex
+	plugins map[string]*plugin
+	// next holds the plugins from Load until they are committed or discarded
+	next map[string]*plugin
+}
+
+//NewManager creates a Manager without any plugins
+func NewManager() *Manager {
+	return &Manager{plugins: map[string]*plugin{}}
+}
+
+//Load asks each plugin which intents it handles, and returns them built.
+//The plugins are asked at the same time. The ones which fail to answer, or whose intents are invalid
+//or share a phrase with existing or with the intents of another plugin, are logged and skipped.
+//The loaded plugins replace the previous ones only after Commit. Until then, Action uses them
+//to build the intents, so that the whole configuration can be validated first.
+func (m *Manager) Load(ctx context.Context, defs []Definition, existing []*intents.Intent) ([]*intents.Intent, error) {
+	var list []*plugin
+	names := map[string]bool{}
+	for _, def := range defs {
+		if def.Name == "" || def.Command == "" {
+			return nil, fmt.Errorf("plugin %q needs both a name and a command", def.Name)
+		}
+		if names[def.Name] {
+			return nil, fmt.Errorf("plugin %q is defined twice", def.Name)
+		}
+		names[def.Name] = true
+
+		p := &plugin{Definition: def, timeout: defaultTimeout}
+		if def.Timeout != "" {
+			var err error
+			p.timeout, err = normalize.Duration(def.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("plugin %q has an invalid timeout: %w", def.Name, err)
+			}
+		}
+		list = append(list, p)
+	}
+
+	descs := make([]*Description, len(list))
+	var wg sync.WaitGroup
+	for idx, p := range list {
+		wg.Add(1)
+		go func(idx int, p *plugin) {
+			defer wg.Done()
+			desc := &Description{}
+			if err := p.call(ctx, Message{Type: TypeDescribe}, desc); err != nil {
+				log.Printf("skipping the plugin %q: %v\n", p.Name, err)
+				return
+			}
+			descs[idx] = desc
+		}(idx, p)
+	}
+	wg.Wait()
+
+	// Action has to find the plugins to build their intents
+	loaded := map[string]*plugin{}
+	for idx, p := range list {
+		if descs[idx] != nil {
+			loaded[p.Name] = p
+		}
+	}
+	m.mu.Lock()
+	m.next = loaded
+	m.mu.Unlock()
+
+	accepted := append([]*intents.Intent{}, existing...)
+	var res []*intents.Intent
+	for idx, p := range list {
+		desc := descs[idx]
+		if desc == nil {
+			continue
+		}
+		built, err := intents.BuildAll(p.definitions(desc))
+		if err == nil {
+			err = intents.CheckPhrases(append(append([]*intents.Intent{}, accepted...), built...))
+		}
+		if err != nil {
+			log.Printf("skipping the plugin %q: %v\n", p.Name, err)
+			m.mu.Lock()
+			delete(loaded, p.Name)
+			m.mu.Unlock()
+			continue
+		}
+		accepted = append(accepted, built...)
+		res = append(res, built...)
+		log.Printf("plugin %q handles %d intents\n", p.Name, len(built))
+	}
+	return res, nil
+}
+
+// definitions converts the intents advertised by the plugin to intent definitions which run them
+func (p *plugin) definitions(desc *Description) []intents.Definition {
+	var res []intents.Definition
+	for _, i := range desc.Intents {
+		if i.Category == "" {
+			i.Category = p.Name
+		}
+		res = append(res, intents.Definition{
+			Command:      i.Command,
+			Alternatives: i.Alternatives,
+			Prompts:      i.Prompts,
+			Language:     i.Language,
+			Category:     i.Category,
+			Actions: []intents.ActionDefinition{{
+				Name:   ActionName,
+				Params: intents.Params{"plugin": p.Name, "intent": i.Command},
+			}},
+		})
+	}
+	return res
+}
+
+//Commit replaces the current plugins with the ones from the last Load
+func (m *Manager) Commit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.next != nil {
+		m.plugins = m.next
+		m.next = nil
+	}
+}
+
+//Discard forgets the plugins from the last Load and keeps the current ones
+func (m *Manager) Discard() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next = nil
+}
+
+//Action creates the Action which runs an intent of a plugin. It is registered as ActionName.
+func (m *Manager) Action(params intents.Params) (intents.Action, error) {
+	m.mu.RLock()
+	plugins := m.plugins
+	if m.next != nil {
+		plugins = m.next
+	}
+	p, ok := plugins[params["plugin"]]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin %q", params["plugin"])
+	}
+	intent := params["intent"]
+	if intent == "" {
+		return nil, fmt.Errorf("plugin %q needs the intent to run", p.Name)
+	}
+
+	return func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+		msg := Message{
+			Type:       TypeExecute,
+			Intent:     intent,
+			Phrase:     req.Phrase,
+			Transcript: req.Transcript,
+			Slots:      req.Slots,
+			Language:   i18n.Language(ctx),
+			Source:     string(req.Source),
+		}
+		resp := Response{}
+		if err := p.call(ctx, msg, &resp); err != nil {
+			return err
+		}
+
+		switch resp.Status {
+		case StatusOK:
+			if resp.Speech != "" {
+				ttsService.Speak(ctx, resp.Speech)
+			}
+			return nil
+		case StatusError:
+			if resp.Error == "" {
+				resp.Error = resp.Speech
+			}
+			return errors.New(resp.Error)
+		default:
+			return fmt.Errorf("plugin %q answered with an unknown status %q", p.Name, resp.Status)
+		}
+	}, nil
+}
+
+// call runs the plugin with the message on its standard input and decodes its answer into res
+func (p *plugin) call(ctx context.Context, msg Message, res interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	msg.Version = Version
+	in, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// The children of the plugin can keep its output open after it is killed, so the timeout
+	// doesn't wait for them
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Run()
+	}()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("plugin %q: %w", p.Name, ctx.Err())
+	case err = <-done:
+	}
+
+	if logs := strings.TrimSpace(stderr.String()); logs != "" {
+		log.Printf("plugin %q: %s\n", p.Name, logs)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("plugin %q: %w", p.Name, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("plugin %q failed to %s: %w", p.Name, msg.Type, err)
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), res); err != nil {
+		return fmt.Errorf("plugin %q gave an invalid answer to %s: %w", p.Name, msg.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/tts"
+)
+
+// TestHelperProcess is not a real test. The plugins in the tests run the test binary again,
+// which then acts as the plugin named after "--" in its arguments.
+func TestHelperProcess(t *testing.T) {
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return
+	}
+
+	msg := Message{}
+	if err := json.NewDecoder(os.Stdin).Decode(&msg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var res interface{}
+	switch args[1] {
+	case "garage":
+		res = Description{Intents: []Intent{{Command: "open the garage", Alternatives: []string{"open the {door} door"}}}}
+		if msg.Type == TypeExecute {
+			switch door := msg.Slots["door"]; door {
+			case "":
+				res = Response{Status: StatusOK, Speech: "Opening the garage."}
+			case "attic":
+				res = Response{Status: StatusError, Error: "there is no attic door"}
+			default:
+				res = Response{Status: StatusOK, Speech: "Opening the " + door + " door."}
+			}
+		}
+	case "weather":
+		res = Description{Intents: []Intent{{Command: "what is the weather", Category: "outside"}}}
+	case "broken":
+		fmt.Println("not json")
+		os.Exit(0)
+	case "slow":
+		time.Sleep(10 * time.Second)
+	case "invalid":
+		res = Description{Intents: []Intent{{Command: "open the {door"}}}
+	case "duplicate":
+		res = Description{Intents: []Intent{{Command: "open the garage"}}}
+	case "taken":
+		res = Description{Intents: []Intent{{Command: "turn on the lamp"}}}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(res); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// fake returns the definition of a plugin implemented by TestHelperProcess
+func fake(name string) Definition {
+	return Definition{
+		Name:    name,
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestHelperProcess", "--", name},
+		Timeout: "5s",
+	}
+}
+
+func newManager() *Manager {
+	m := NewManager()
+	intents.RegisterAction(ActionName, m.Action)
+	return m
+}
+
+func commands(list []*intents.Intent) []string {
+	var res []string
+	for _, intent := range list {
+		res = append(res, intent.Command)
+	}
+	return res
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDescribe(t *testing.T) {
+	m := newManager()
+	list, err := m.Load(context.Background(), []Definition{fake("garage"), fake("weather")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := commands(list); !equal(got, []string{"open the garage", "what is the weather"}) {
+		t.Fatalf("got the intents %q", got)
+	}
+	if got := list[0].Alternatives; !equal(got, []string{"open the {door} door"}) {
+		t.Errorf("got the alternatives %q", got)
+	}
+	if list[0].Category != "garage" {
+		t.Errorf("got the category %q instead of the name of the plugin", list[0].Category)
+	}
+	if list[1].Category != "outside" {
+		t.Errorf("got the category %q instead of the advertised one", list[1].Category)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	m := newManager()
+	list, err := m.Load(context.Background(), []Definition{fake("garage")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Commit()
+
+	tests := []struct {
+		door   string
+		speech string
+		err    string
+	}{
+		{door: "", speech: "PHAS: Opening the garage.\n"},
+		{door: "front", speech: "PHAS: Opening the front door.\n"},
+		{door: "attic", err: "there is no attic door"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		req := &intents.Request{Transcript: "open the door", Slots: intents.Slots{}}
+		if test.door != "" {
+			req.Slots["door"] = test.door
+		}
+		err := list[0].Steps[0].Action(context.Background(), req, tts.NewConsole(&out))
+		switch {
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("door %q: got the error %v instead of %q", test.door, err, test.err)
+		case test.err == "" && err != nil:
+			t.Errorf("door %q: %v", test.door, err)
+		case out.String() != test.speech:
+			t.Errorf("door %q: got the speech %q instead of %q", test.door, out.String(), test.speech)
+		}
+	}
+}
+
+func TestBadPlugins(t *testing.T) {
+	existing := []*intents.Intent{{Command: "turn on the lamp"}}
+	slow := fake("slow")
+	slow.Timeout = "1s"
+
+	m := newManager()
+	list, err := m.Load(context.Background(), []Definition{
+		fake("garage"), fake("broken"), slow, fake("invalid"), fake("duplicate"), fake("taken"), fake("weather"),
+	}, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := commands(list); !equal(got, []string{"open the garage", "what is the weather"}) {
+		t.Errorf("got the intents %q", got)
+	}
+
+	m.Commit()
+	for _, name := range []string{"broken", "slow", "invalid", "duplicate", "taken"} {
+		if _, err := m.Action(intents.Params{"plugin": name, "intent": "open the garage"}); err == nil {
+			t.Errorf("the plugin %q was loaded", name)
+		}
+	}
+}
+
+func TestInvalidDefinitions(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []Definition
+		err  string
+	}{
+		{name: "no command", defs: []Definition{{Name: "garage"}}, err: "needs both a name and a command"},
+		{name: "twice", defs: []Definition{fake("garage"), fake("garage")}, err: "is defined twice"},
+		{name: "timeout", defs: []Definition{{Name: "garage", Command: "garage", Timeout: "soon"}}, err: "invalid timeout"},
+	}
+	for _, test := range tests {
+		_, err := newManager().Load(context.Background(), test.defs, nil)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got the error %v instead of %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestCommitAndDiscard(t *testing.T) {
+	m := newManager()
+	params := intents.Params{"plugin": "garage", "intent": "open the garage"}
+
+	if _, err := m.Load(context.Background(), []Definition{fake("garage")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	m.Discard()
+	if _, err := m.Action(params); err == nil {
+		t.Fatal("the discarded plugin is used")
+	}
+
+	if _, err := m.Load(context.Background(), []Definition{fake("garage")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	m.Commit()
+	if _, err := m.Action(params); err != nil {
+		t.Fatal(err)
+	}
+
+	// The current plugins stay until the next ones are committed
+	if _, err := m.Load(context.Background(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	m.Discard()
+	if _, err := m.Action(params); err != nil {
+		t.Fatalf("the committed plugin was dropped: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+
+package plugins
+
+import "github.com/dlsniper/phas/commands/intents"
+
+//Version is the version of the protocol spoken with the plugins
+const Version = 1
+
+//The types of the messages sent to a plugin
+const (
+	//TypeDescribe asks the plugin which intents it handles
+	TypeDescribe = "describe"
+	//TypeExecute asks the plugin to run one of its intents
+	TypeExecute = "execute"
+)
+
+//The statuses a plugin can answer with
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+//Message is written as JSON to the standard input of the plugin
+type Message struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+
+	//The fields below are only set for TypeExecute
+	Intent     string        `json:"intent,omitempty"`
+	Phrase     string        `json:"phrase,omitempty"`
+	Transcript string        `json:"transcript,omitempty"`
+	Slots      intents.Slots `json:"slots,omitempty"`
+	Language   string        `json:"language,omitempty"`
+	Source     string        `json:"source,omitempty"`
+}
+
+//Intent is an intent advertised by a plugin
+type Intent struct {
+	Command      string            `json:"command"`
+	Alternatives []string          `json:"alternatives,omitempty"`
+	Prompts      map[string]string `json:"prompts,omitempty"`
+	Language     string            `json:"language,omitempty"`
+	Category     string            `json:"category,omitempty"`
+}
+
+//Description is the answer of the plugin to TypeDescribe
+type Description struct {
+	Intents []Intent `json:"intents"`
+}
+
+//Response is the answer of the plugin to TypeExecute
+type Response struct {
+	Status string `json:"status"`
+	//Speech is said to the user when the status is StatusOK
+	Speech string `json:"speech,omitempty"`
+	//Error explains what went wrong when the status is StatusError
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,372 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routines
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/normalize"
+	"github.com/dlsniper/phas/tts"
+)
+
+//Condition decides if a routine step runs. All the given fields must be true.
+type Condition struct {
+	//After and Before are times of the day such as "22:00". When After is later than Before, the range passes midnight.
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
+	//Days lists the days of the week, such as "mon" or "sat"
+	Days []string `json:"days,omitempty"`
+	//Check names a check registered with RegisterCheck
+	Check string `json:"check,omitempty"`
+}
+
+//A Step runs an existing intent, after an optional delay, when its condition is true
+type Step struct {
+	Intent string     `json:"intent"`
+	Delay  string     `json:"delay,omitempty"`
+	If     *Condition `json:"if,omitempty"`
+}
+
+//Definition describes a routine in the configuration file
+type Definition struct {
+	Name    string   `json:"name"`
+	Phrases []string `json:"phrases"`
+	Steps   []Step   `json:"steps"`
+}
+
+var (
+	checksMu sync.RWMutex
+	checks   = map[string]func() bool{}
+)
+
+//RegisterCheck makes a named check available to the routine conditions
+func RegisterCheck(name string, check func() bool) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	checks[name] = check
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of the day %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (c *Condition) validate() error {
+	for _, value := range []string{c.After, c.Before} {
+		if value == "" {
+			continue
+		}
+		if _, err := parseClock(value); err != nil {
+			return err
+		}
+	}
+
+	for _, day := range c.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+	}
+
+	if c.Check != "" {
+		checksMu.RLock()
+		_, ok := checks[c.Check]
+		checksMu.RUnlock()
+		if !ok {
+			return fmt.Errorf("unknown check %q", c.Check)
+		}
+	}
+
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func (c *Condition) holds(now time.Time) bool {
+	if c == nil {
+		return true
+	}
+
+	if len(c.Days) > 0 {
+		found := false
+		for _, day := range c.Days {
+			if weekdays[strings.ToLower(day)] == now.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if c.After != "" || c.Before != "" {
+		clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+		after, _ := parseClock(c.After)
+		before := 24 * time.Hour
+		if c.Before != "" {
+			before, _ = parseClock(c.Before)
+		}
+		if after <= before && (clock < after || clock >= before) {
+			return false
+		}
+		if after > before && clock < after && clock >= before {
+			return false
+		}
+	}
+
+	if c.Check != "" {
+		checksMu.RLock()
+		check := checks[c.Check]
+		checksMu.RUnlock()
+		if check == nil || !check() {
+			return false
+		}
+	}
+
+	return true
+}
+
+type step struct {
+	intent *intents.Intent
+	phrase string
+	slots  intents.Slots
+	delay  time.Duration
+	cond   *Condition
+}
+
+//Runner runs the routines in the background and lets the user cancel them
+type Runner struct {
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+//NewRunner creates a new Runner
+func NewRunner() *Runner {
+	return &Runner{running: map[string]context.CancelFunc{}}
+}
+
+//Build validates the routine and creates the Intent which starts it. The steps can use any of the given intents.
+func (r *Runner) Build(def Definition, available []*intents.Intent) (*intents.Intent, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("routine without a name")
+	}
+	if len(def.Phrases) == 0 {
+		return nil, fmt.Errorf("routine %q has no phrases", def.Name)
+	}
+	if len(def.Steps) == 0 {
+		return nil, fmt.Errorf("routine %q has no steps", def.Name)
+	}
+
+	var steps []step
+	for idx, s := range def.Steps {
+		phrase := strings.ToLower(s.Intent)
+		match := intents.Find(available, phrase)
+		if match == nil {
+			return nil, fmt.Errorf("routine %q step %d uses unknown intent %q", def.Name, idx+1, s.Intent)
+		}
+
+		var delay time.Duration
+		if s.Delay != "" {
+			var err error
+			delay, err = normalize.Duration(s.Delay)
+			if err != nil {
+				return nil, fmt.Errorf("routine %q step %d has an invalid delay: %w", def.Name, idx+1, err)
+			}
+		}
+
+		if s.If != nil {
+			if err := s.If.validate(); err != nil {
+				return nil, fmt.Errorf("routine %q step %d: %w", def.Name, idx+1, err)
+			}
+		}
+
+		steps = append(steps, step{
+			intent: match.Intent,
+			phrase: phrase,
+			slots:  match.Slots,
+			delay:  delay,
+			cond:   s.If,
+		})
+	}
+
+	return &intents.Intent{
+		Command:      def.Phrases[0],
+		Alternatives: def.Phrases[1:],
+		Category:     "routines",
+		Steps: []intents.Step{
+			{
+				Name: "the " + def.Name + " routine",
+				Action: func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+					return r.start(ctx, def.Name, steps, req, ttsService)
+				},
+			},
+		},
+	}, nil
+}
+
+// start runs the routine steps in the background, so that the routine can be cancelled while it waits.
+// Stopping the command which started the routine stops it as well, until the command is done.
+func (r *Runner) start(ctx context.Context, name string, steps []step, req *intents.Request, ttsService *tts.Service) error {
+	r.mu.Lock()
+	if _, ok := r.running[name]; ok {
+		r.mu.Unlock()
+		return fmt.Errorf("the %s routine is already running", name)
+	}
+	// The routine outlives the command which started it, but keeps its language
+	routineCtx, cancel := context.WithCancel(i18n.WithLanguage(context.Background(), req.Language))
+	r.running[name] = cancel
+	r.mu.Unlock()
+
+	done := func() {
+		cancel()
+		r.mu.Lock()
+		delete(r.running, name)
+		r.mu.Unlock()
+	}
+
+	// The steps before the first delay run right away, so they can still ask the user questions.
+	// They end when either the command or the routine is stopped.
+	commandCtx, stopCommand := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-routineCtx.Done():
+			stopCommand()
+		case <-commandCtx.Done():
+		}
+	}()
+	ttsService.Speak(commandCtx, i18n.Sprintf(commandCtx, "Starting the %s routine.", name))
+	idx := 0
+	for idx < len(steps) && steps[idx].delay == 0 {
+		if commandCtx.Err() != nil {
+			log.Printf("routine %q cancelled before step %d\n", name, idx+1)
+			break
+		}
+		if !steps[idx].run(commandCtx, name, idx, req, ttsService) {
+			break
+		}
+		idx++
+	}
+	// The rest of the steps only run when the ones so far did, and the routine wasn't stopped
+	later := idx < len(steps) && steps[idx].delay > 0 && commandCtx.Err() == nil
+	stopCommand()
+	if !later {
+		done()
+		return nil
+	}
+
+	// The user may be giving other commands while the rest of the steps run, so they can't ask questions
+	background := *req
+	background.Dialog = nil
+	go func() {
+		defer done()
+		for ; idx < len(steps); idx++ {
+			s := steps[idx]
+			if s.delay > 0 {
+				t := time.NewTimer(s.delay)
+				select {
+				case <-routineCtx.Done():
+					t.Stop()
+					log.Printf("routine %q cancelled before step %d\n", name, idx+1)
+					return
+				case <-t.C:
+				}
+			}
+			if !s.run(routineCtx, name, idx, &background, ttsService) {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// run executes the step of the routine when its condition holds, and tells if the routine can continue
+func (s step) run(ctx context.Context, routine string, idx int, req *intents.Request, ttsService *tts.Service) bool {
+	if !s.cond.holds(time.Now()) {
+		log.Printf("routine %q skips step %d, %q\n", routine, idx+1, s.phrase)
+		return true
+	}
+
+	stepReq := *req
+	stepReq.Transcript = s.phrase
+	stepReq.Phrase = s.phrase
+	// Each run gets its own slots, as the Intent adds the answers to its prompts to them
+	stepReq.Slots = intents.Slots{}
+	for slot, value := range s.slots {
+		stepReq.Slots[slot] = value
+	}
+	result := s.intent.Execute(ctx, &stepReq, ttsService)
+	if err := result.Err(); err != nil {
+		log.Printf("routine %q stopped: %v\n", routine, err)
+		return false
+	}
+	return true
+}
+
+//Cancel stops the named routine, or all the running routines when the name is empty, and returns how many were stopped
+func (r *Runner) Cancel(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for routine, cancel := range r.running {
+		if name == "" || strings.EqualFold(routine, name) {
+			cancel()
+			count++
+		}
+	}
+	return count
+}
+
+//CancelIntent creates the Intent which lets the user stop the running routines
+func (r *Runner) CancelIntent() *intents.Intent {
+	return &intents.Intent{
+		Command:      "cancel the routine",
+		Alternatives: []string{"stop the routine", "cancel the routines", "cancel the {routine} routine", "stop the {routine} routine"},
+		Category:     "routines",
+		Steps: []intents.Step{
+			{
+				Name: "cancelling the routine",
+				Action: func(ctx context.Context, req *intents.Request, ttsService *tts.Service) error {
+					name := req.Slot("routine")
+					if r.Cancel(name) == 0 {
+						ttsService.Speak(ctx, i18n.Sprintf(ctx, "There is no routine running."))
+						return nil
+					}
+					ttsService.Speak(ctx, i18n.Sprintf(ctx, "The routine is cancelled."))
+					return nil
+				},
+			},
+		},
+	}
+}
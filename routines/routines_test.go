@@ -0,0 +1,327 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routines
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/tts"
+)
+
+// recorder provides the intents used by the routine steps, and records which ones ran
+type recorder struct {
+	mu    sync.Mutex
+	ran   []string
+	waits chan struct{}
+}
+
+func (r *recorder) intent(command string) *intents.Intent {
+	return &intents.Intent{
+		Command: command,
+		Steps: []intents.Step{{
+			Name: command,
+			Action: func(context.Context, *intents.Request, *tts.Service) error {
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				r.ran = append(r.ran, command)
+				return nil
+			},
+		}},
+	}
+}
+
+func (r *recorder) steps() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.ran...)
+}
+
+func (r *recorder) available() []*intents.Intent {
+	return []*intents.Intent{r.intent("turn on the lamp"), r.intent("turn off the lamp"), r.intent("play the radio"), r.waiting()}
+}
+
+// waiting returns the intent which waits until its context is done, and tells when it started on r.waits
+func (r *recorder) waiting() *intents.Intent {
+	return &intents.Intent{
+		Command: "wait for the door",
+		Steps: []intents.Step{{
+			Name: "wait for the door",
+			Action: func(ctx context.Context, _ *intents.Request, _ *tts.Service) error {
+				r.waits <- struct{}{}
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}},
+	}
+}
+
+// startRoutine builds the routine and starts it as a command would
+func startRoutine(t *testing.T, r *Runner, rec *recorder, def Definition) {
+	t.Helper()
+	intent, err := r.Build(def, rec.available())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	req := &intents.Request{Transcript: def.Phrases[0], Phrase: def.Phrases[0]}
+	if err := intent.Execute(context.Background(), req, tts.NewConsole(io.Discard)).Err(); err != nil {
+		t.Fatalf("the routine failed to start: %v", err)
+	}
+}
+
+// waitIdle waits for the routines of the Runner to end
+func waitIdle(t *testing.T, r *Runner) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r.mu.Lock()
+		count := len(r.running)
+		r.mu.Unlock()
+		if count == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the routine is still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBuildErrors(t *testing.T) {
+	rec := &recorder{}
+	steps := []Step{{Intent: "turn on the lamp"}}
+	for _, def := range []Definition{
+		{Phrases: []string{"good night"}, Steps: steps},
+		{Name: "night", Steps: steps},
+		{Name: "night", Phrases: []string{"good night"}},
+		{Name: "night", Phrases: []string{"good night"}, Steps: []Step{{Intent: "open the door"}}},
+		{Name: "night", Phrases: []string{"good night"}, Steps: []Step{{Intent: "turn on the lamp", Delay: "soon"}}},
+		{Name: "night", Phrases: []string{"good night"}, Steps: []Step{{Intent: "turn on the lamp", If: &Condition{After: "25:00"}}}},
+		{Name: "night", Phrases: []string{"good night"}, Steps: []Step{{Intent: "turn on the lamp", If: &Condition{Days: []string{"someday"}}}}},
+		{Name: "night", Phrases: []string{"good night"}, Steps: []Step{{Intent: "turn on the lamp", If: &Condition{Check: "unknown"}}}},
+	} {
+		if _, err := NewRunner().Build(def, rec.available()); err == nil {
+			t.Errorf("Build(%+v) succeeded, want an error", def)
+		}
+	}
+}
+
+func TestConditionHolds(t *testing.T) {
+	RegisterCheck("test_on", func() bool { return true })
+	RegisterCheck("test_off", func() bool { return false })
+	// 2021-03-01 is a Monday
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, time.March, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		cond *Condition
+		now  time.Time
+		want bool
+	}{
+		{nil, at(12, 0), true},
+		{&Condition{After: "08:00", Before: "18:00"}, at(12, 0), true},
+		{&Condition{After: "08:00", Before: "18:00"}, at(18, 0), false},
+		{&Condition{After: "08:00", Before: "18:00"}, at(7, 59), false},
+		{&Condition{After: "22:00", Before: "06:00"}, at(23, 30), true},
+		{&Condition{After: "22:00", Before: "06:00"}, at(5, 0), true},
+		{&Condition{After: "22:00", Before: "06:00"}, at(12, 0), false},
+		{&Condition{After: "20:00"}, at(21, 0), true},
+		{&Condition{Before: "06:00"}, at(21, 0), false},
+		{&Condition{Days: []string{"Mon", "tue"}}, at(12, 0), true},
+		{&Condition{Days: []string{"sat", "sun"}}, at(12, 0), false},
+		{&Condition{Check: "test_on"}, at(12, 0), true},
+		{&Condition{Check: "test_off"}, at(12, 0), false},
+		{&Condition{Days: []string{"mon"}, Check: "test_off"}, at(12, 0), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cond.holds(tt.now); got != tt.want {
+			t.Errorf("%+v holds at %s = %v, want %v", tt.cond, tt.now.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestRoutine(t *testing.T) {
+	RegisterCheck("test_off", func() bool { return false })
+	rec := &recorder{}
+	r := NewRunner()
+
+	startRoutine(t, r, rec, Definition{
+		Name:    "evening",
+		Phrases: []string{"good evening"},
+		Steps: []Step{
+			{Intent: "turn on the lamp"},
+			{Intent: "play the radio", If: &Condition{Check: "test_off"}},
+			{Intent: "turn off the lamp", Delay: "50ms"},
+		},
+	})
+	// The steps before the first delay have run when the command is done
+	if got, want := rec.steps(), []string{"turn on the lamp"}; !equal(got, want) {
+		t.Errorf("got the steps %v before the delay, want %v", got, want)
+	}
+
+	waitIdle(t, r)
+	if got, want := rec.steps(), []string{"turn on the lamp", "turn off the lamp"}; !equal(got, want) {
+		t.Errorf("got the steps %v, want %v", got, want)
+	}
+}
+
+func TestRoutineAlreadyRunning(t *testing.T) {
+	rec := &recorder{}
+	r := NewRunner()
+	def := Definition{
+		Name:    "evening",
+		Phrases: []string{"good evening"},
+		Steps:   []Step{{Intent: "turn off the lamp", Delay: "10 seconds"}},
+	}
+	startRoutine(t, r, rec, def)
+	defer r.Cancel("")
+
+	intent, err := r.Build(def, rec.available())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &intents.Request{Transcript: "good evening", Phrase: "good evening"}
+	if err := intent.Execute(context.Background(), req, tts.NewConsole(io.Discard)).Err(); err == nil {
+		t.Error("the routine started twice, want an error")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	rec := &recorder{}
+	r := NewRunner()
+
+	startRoutine(t, r, rec, Definition{
+		Name:    "evening",
+		Phrases: []string{"good evening"},
+		Steps: []Step{
+			{Intent: "turn on the lamp"},
+			{Intent: "turn off the lamp", Delay: "10 seconds"},
+		},
+	})
+	if n := r.Cancel("Morning"); n != 0 {
+		t.Errorf("Cancel of another routine stopped %d routines", n)
+	}
+	// The routine waits for its delay, and is stopped by its name in any case
+	if n := r.Cancel("Evening"); n != 1 {
+		t.Errorf("Cancel stopped %d routines, want 1", n)
+	}
+
+	waitIdle(t, r)
+	if got, want := rec.steps(), []string{"turn on the lamp"}; !equal(got, want) {
+		t.Errorf("got the steps %v, want %v", got, want)
+	}
+}
+
+func TestCancelIntent(t *testing.T) {
+	rec := &recorder{}
+	r := NewRunner()
+	startRoutine(t, r, rec, Definition{
+		Name:    "evening",
+		Phrases: []string{"good evening"},
+		Steps:   []Step{{Intent: "turn off the lamp", Delay: "10 seconds"}},
+	})
+
+	cancel := r.CancelIntent()
+	match := intents.Find([]*intents.Intent{cancel}, "cancel the evening routine")
+	if match == nil {
+		t.Fatal("cancel the evening routine didn't match the cancel intent")
+	}
+	req := &intents.Request{Transcript: "cancel the evening routine", Phrase: match.Phrase, Slots: match.Slots}
+	if err := cancel.Execute(context.Background(), req, tts.NewConsole(io.Discard)).Err(); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+
+	waitIdle(t, r)
+	if got := rec.steps(); len(got) != 0 {
+		t.Errorf("got the steps %v after the cancel, want none", got)
+	}
+}
+
+// waitingRoutine starts a routine which waits in its first steps, and returns when the command running it is done
+func waitingRoutine(ctx context.Context, t *testing.T, r *Runner, rec *recorder) <-chan struct{} {
+	t.Helper()
+	intent, err := r.Build(Definition{
+		Name:    "door",
+		Phrases: []string{"watch the door"},
+		Steps: []Step{
+			{Intent: "wait for the door"},
+			{Intent: "turn on the lamp"},
+			{Intent: "turn off the lamp", Delay: "10ms"},
+		},
+	}, rec.available())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		req := &intents.Request{Transcript: "watch the door", Phrase: "watch the door"}
+		intent.Execute(ctx, req, tts.NewConsole(io.Discard))
+	}()
+	<-rec.waits
+	return finished
+}
+
+func TestStopCommand(t *testing.T) {
+	rec := &recorder{waits: make(chan struct{}, 1)}
+	r := NewRunner()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	finished := waitingRoutine(ctx, t, r, rec)
+	// The user says "stop" while the first step runs
+	cancel()
+	<-finished
+
+	waitIdle(t, r)
+	if got := rec.steps(); len(got) != 0 {
+		t.Errorf("got the steps %v after the stop, want none", got)
+	}
+}
+
+func TestCancelFirstSteps(t *testing.T) {
+	rec := &recorder{waits: make(chan struct{}, 1)}
+	r := NewRunner()
+
+	finished := waitingRoutine(context.Background(), t, r, rec)
+	if n := r.Cancel(""); n != 1 {
+		t.Errorf("Cancel stopped %d routines, want 1", n)
+	}
+	<-finished
+
+	waitIdle(t, r)
+	if got := rec.steps(); len(got) != 0 {
+		t.Errorf("got the steps %v after the cancel, want none", got)
+	}
+}
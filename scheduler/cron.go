@@ -0,0 +1,158 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Cron is a parsed cron expression with the minute, hour, day of month, month, and day of week fields
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+//ParseCron parses a standard five fields cron expression, such as "0 22 * * mon-fri", or a macro such as "@daily"
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(strings.ToLower(expr))
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	c := &Cron{
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	// 7 is also Sunday
+	if c.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+func parseValue(value string, names map[string]int) (int, error) {
+	if n, ok := names[value]; ok {
+		return n, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// parseField returns the allowed values of a field as a bit set
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var res uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", field)
+			}
+			part = part[:idx]
+		}
+
+		low, high := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if low, err = parseValue(bounds[0], names); err != nil {
+				return 0, fmt.Errorf("invalid range in %q", field)
+			}
+			if high, err = parseValue(bounds[1], names); err != nil {
+				return 0, fmt.Errorf("invalid range in %q", field)
+			}
+		default:
+			var err error
+			if low, err = parseValue(part, names); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", field)
+			}
+			high = low
+			if step > 1 {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", field, min, max)
+		}
+		for v := low; v <= high; v += step {
+			res |= 1 << uint(v)
+		}
+	}
+	return res, nil
+}
+
+//Matches tells if the cron expression runs at the given minute
+func (c *Cron) Matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 ||
+		c.hour&(1<<uint(t.Hour())) == 0 ||
+		c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	// Like in cron, when both days are restricted, either of them can match
+	if !c.domAny && !c.dowAny {
+		return dom || dow
+	}
+	return dom && dow
+}
@@ -0,0 +1,117 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@sometimes",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2021-03-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2021, time.March, day, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		expr  string
+		time  time.Time
+		match bool
+	}{
+		{"* * * * *", at(1, 12, 34), true},
+		{"0 22 * * mon-fri", at(1, 22, 0), true},
+		{"0 22 * * mon-fri", at(6, 22, 0), false},
+		{"0 22 * * mon-fri", at(1, 22, 1), false},
+		{"*/15 * * * *", at(1, 10, 45), true},
+		{"*/15 * * * *", at(1, 10, 50), false},
+		{"5/20 * * * *", at(1, 10, 45), true},
+		{"0,30 9-17 * * *", at(1, 17, 30), true},
+		{"0,30 9-17 * * *", at(1, 18, 0), false},
+		{"0 8 * mar sun", at(7, 8, 0), true},
+		{"0 8 * * 7", at(7, 8, 0), true},
+		{"0 8 * apr *", at(7, 8, 0), false},
+		// When both days are restricted, either of them matches
+		{"0 8 15 * mon", at(1, 8, 0), true},
+		{"0 8 15 * mon", at(15, 8, 0), true},
+		{"0 8 15 * mon", at(16, 8, 0), false},
+		{"@daily", at(2, 0, 0), true},
+		{"@daily", at(2, 0, 1), false},
+		{"@weekly", at(7, 0, 0), true},
+		{"@monthly", at(1, 0, 0), true},
+		{"@hourly", at(3, 5, 0), true},
+		{"  0 22 * * MON-FRI ", at(2, 22, 0), true},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.Matches(tt.time); got != tt.match {
+			t.Errorf("%q matches %v = %v, want %v", tt.expr, tt.time, got, tt.match)
+		}
+	}
+}
+
+func TestDefinitionExpression(t *testing.T) {
+	tests := []struct {
+		def  Definition
+		want string
+		err  bool
+	}{
+		{def: Definition{Cron: "0 7 * * *"}, want: "0 7 * * *"},
+		{def: Definition{At: "half past six pm"}, want: "30 18 * * *"},
+		{def: Definition{At: "7:15 am", Days: "mon-fri"}, want: "15 7 * * mon-fri"},
+		{def: Definition{Cron: "0 7 * * *", At: "7 am"}, err: true},
+		{def: Definition{Cron: "0 7 * * *", Days: "mon"}, err: true},
+		{def: Definition{At: "soon"}, err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.def.expression()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%+v gave %q, want an error", tt.def, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%+v gave %q, %v, want %q", tt.def, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dlsniper/phas/commands/intents"
+	"github.com/dlsniper/phas/normalize"
+)
+
+//Definition describes a scheduled command in the configuration file.
+//The schedule is either a cron expression, or a time of the day, such as "half past six pm",
+//with optional days of the week in the cron format, such as "mon-fri".
+type Definition struct {
+	Name    string `json:"name,omitempty"`
+	Cron    string `json:"cron,omitempty"`
+	At      string `json:"at,omitempty"`
+	Days    string `json:"days,omitempty"`
+	Command string `json:"command"`
+}
+
+type entry struct {
+	def  Definition
+	cron *Cron
+}
+
+//Service runs commands on a schedule through the same pipeline as the voice commands
+type Service struct {
+	submit func(*intents.Request)
+
+	mu      sync.Mutex
+	entries []entry
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+//New creates a new Service which hands the commands over to submit when they are due
+func New(submit func(*intents.Request)) *Service {
+	return &Service{submit: submit}
+}
+
+//Validate checks the Definition and returns its parsed cron expression.
+//The command must match one of the given intents exactly.
+func (d Definition) Validate(available []*intents.Intent) (*Cron, error) {
+	expr, err := d.expression()
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q: %w", d.name(), err)
+	}
+	c, err := ParseCron(expr)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q: %w", d.name(), err)
+	}
+	if intents.Find(available, d.Command) == nil {
+		return nil, fmt.Errorf("schedule %q uses unknown command %q", d.name(), d.Command)
+	}
+	return c, nil
+}
+
+//expression returns the cron expression of the Definition
+func (d Definition) expression() (string, error) {
+	if d.At == "" {
+		if d.Days != "" {
+			return "", fmt.Errorf("days can only be used together with at")
+		}
+		return d.Cron, nil
+	}
+	if d.Cron != "" {
+		return "", fmt.Errorf("use either cron or at, not both")
+	}
+
+	hour, minute, err := normalize.TimeOfDay(d.At)
+	if err != nil {
+		return "", err
+	}
+	days := d.Days
+	if days == "" {
+		days = "*"
+	}
+	return fmt.Sprintf("%d %d * * %s", minute, hour, days), nil
+}
+
+func (d Definition) when() string {
+	if d.At != "" {
+		return "at " + d.At
+	}
+	return d.Cron
+}
+
+func (d Definition) name() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.Command
+}
+
+//Schedule holds validated Definitions, ready to replace the current schedule
+type Schedule struct {
+	entries []entry
+}
+
+//Build validates the Definitions against the given intents, without changing the current schedule
+func Build(defs []Definition, available []*intents.Intent) (*Schedule, error) {
+	res := &Schedule{}
+	for _, def := range defs {
+		c, err := def.Validate(available)
+		if err != nil {
+			return nil, err
+		}
+		res.entries = append(res.entries, entry{def: def, cron: c})
+	}
+	return res, nil
+}
+
+//Set replaces the current schedule with one from Build
+func (s *Service) Set(schedule *Schedule) {
+	s.mu.Lock()
+	s.entries = schedule.entries
+	s.mu.Unlock()
+}
+
+//Start runs the schedule in the background until Stop is called
+func (s *Service) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			t := time.NewTimer(next.Sub(now))
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			s.run(next)
+		}
+	}()
+}
+
+//Stop stops the schedule and waits for it to finish
+func (s *Service) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+func (s *Service) run(now time.Time) {
+	s.mu.Lock()
+	entries := s.entries
+	s.mu.Unlock()
+
+	for _, e := range entries {
+		if !e.cron.Matches(now) {
+			continue
+		}
+		log.Printf("running the scheduled command %q (%s)\n", e.def.name(), e.def.when())
+		s.submit(&intents.Request{
+			Transcript: e.def.Command,
+			Source:     intents.SourceSchedule,
+		})
+	}
+}
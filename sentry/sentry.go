@@ -22,6 +22,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dlsniper/phas/i18n"
 	"github.com/dlsniper/phas/tts"
 	"github.com/hybridgroup/mjpeg"
 	"gocv.io/x/gocv"
@@ -31,16 +32,21 @@ import (
 type Service struct {
 	camera      int
 	sensibility float64
-	started     bool
 	once        sync.Once
 	gwait, wait chan struct{}
 	state       chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	// armed is done when the sentry mode is turned off
+	armed  context.Context
+	disarm context.CancelFunc
 }
 
 var streamingServerAddr = ":42080"
 
-//New create a new Service
-func New(camera int, sensibility float64, gwait chan struct{}, callback func(sinceLastAlarm float64)) *Service {
+//New create a new Service. The context given to the callback is done when the sentry mode is turned off.
+func New(camera int, sensibility float64, gwait chan struct{}, callback func(ctx context.Context, sinceLastAlarm float64)) *Service {
 	res := &Service{
 		camera:      camera,
 		sensibility: sensibility,
@@ -55,7 +61,7 @@ func New(camera int, sensibility float64, gwait chan struct{}, callback func(sin
 			for {
 				<-res.state
 				now := time.Now()
-				callback(now.Sub(lastAlarm).Seconds())
+				callback(res.armedContext(), now.Sub(lastAlarm).Seconds())
 				lastAlarm = now
 			}
 		}()
@@ -66,17 +72,44 @@ func New(camera int, sensibility float64, gwait chan struct{}, callback func(sin
 
 //Toggle the Service state to on or off
 func (s *Service) Toggle(ctx context.Context, ttsService *tts.Service, desiredSentryMode bool) {
-	if desiredSentryMode && !s.started {
+	s.mu.Lock()
+	if s.started == desiredSentryMode {
+		s.mu.Unlock()
+		return
+	}
+	s.started = desiredSentryMode
+	if desiredSentryMode {
+		s.armed, s.disarm = context.WithCancel(context.Background())
+	} else {
+		s.disarm()
+	}
+	s.mu.Unlock()
+
+	if desiredSentryMode {
 		go func() {
-			ttsService.Speak(ctx, "Sentry mode activated!")
+			ttsService.Speak(ctx, i18n.Sprintf(ctx, "Sentry mode activated!"))
 			s.Start(s.camera, s.sensibility)
 		}()
-		s.started = true
-	} else if !desiredSentryMode && s.started {
-		s.wait <- struct{}{}
-		ttsService.Speak(ctx, "Sentry mode turned off!")
-		s.started = false
+		return
 	}
+	s.wait <- struct{}{}
+	ttsService.Speak(ctx, i18n.Sprintf(ctx, "Sentry mode turned off!"))
+}
+
+func (s *Service) armedContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.armed == nil {
+		return context.Background()
+	}
+	return s.armed
+}
+
+//Started tells if the sentry mode is on
+func (s *Service) Started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
 }
 
 //Start the Service state
@@ -67,6 +67,6 @@ func (m *Service) SendSMS(phoneNumber, message string) error {
 		return err
 	}
 
-	//goland:noinspection GoVetIntToStringConversion
-	return m.send(message+string(26))
+	// Ctrl+Z ends the message
+	return m.send(message + string(rune(26)))
 }
@@ -16,10 +16,10 @@ package stt
 
 import (
 	"context"
-	"log"
+	"fmt"
 
-	"cloud.google.com/go/speech/apiv1"
-	speechpb "google.golang.org/genproto/googleapis/cloud/speech/v1"
+	speech "cloud.google.com/go/speech/apiv1p1beta1"
+	speechpb "google.golang.org/genproto/googleapis/cloud/speech/v1p1beta1"
 )
 
 //Service that handles the speech to text conversion
@@ -39,34 +39,49 @@ func (s *Service) newRequest(content []byte) *speechpb.RecognizeRequest {
 	}
 }
 
-func (s *Service) command(resp *speechpb.RecognizeResponse) string {
+func (s *Service) command(resp *speechpb.RecognizeResponse) (string, string) {
 	command := ""
+	language := ""
 	for idx := range resp.Results {
 		command += resp.Results[idx].Alternatives[0].Transcript
+		if language == "" {
+			language = resp.Results[idx].LanguageCode
+		}
 	}
-	return command
+	if language == "" {
+		language = s.config.LanguageCode
+	}
+	return command, language
 }
 
-//Process processes the incoming voice audio content and transforms it to text
-func (s *Service) Process(ctx context.Context, content []byte) string {
+//Process processes the incoming voice audio content and transforms it to text.
+//It also returns the language that was recognized. It fails when the context is done,
+//e.g. when the user stops the command which is waiting for an answer.
+func (s *Service) Process(ctx context.Context, content []byte) (string, string, error) {
 	req := s.newRequest(content)
 
 	resp, err := s.service.Recognize(ctx, req)
 	if err != nil {
-		log.Fatalln(err)
+		if ctx.Err() != nil {
+			return "", "", ctx.Err()
+		}
+		return "", "", fmt.Errorf("failed to recognize the speech: %w", err)
 	}
 
-	return s.command(resp)
+	command, language := s.command(resp)
+	return command, language, nil
 }
 
-//New creates a new speech to text service
-func New(speechService *speech.Client) *Service {
+//New creates a new speech to text service for the language, such as "en-US".
+//The alternative languages are recognized as well, e.g. in a household that speaks more than one language.
+func New(speechService *speech.Client, language string, alternatives []string) *Service {
 	return &Service{
 		service: speechService,
 		config: &speechpb.RecognitionConfig{
-			LanguageCode: "en-US",
-			Model:        "command_and_search",
-			Encoding:     speechpb.RecognitionConfig_ENCODING_UNSPECIFIED,
+			LanguageCode:             language,
+			AlternativeLanguageCodes: alternatives,
+			Model:                    "command_and_search",
+			Encoding:                 speechpb.RecognitionConfig_ENCODING_UNSPECIFIED,
 		},
 	}
 }
@@ -0,0 +1,270 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package timers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dlsniper/phas/i18n"
+	"github.com/dlsniper/phas/sms"
+	"github.com/dlsniper/phas/tts"
+)
+
+//Kind tells how a Timer is announced
+type Kind string
+
+//The available kinds of timers
+const (
+	KindTimer    Kind = "timer"
+	KindReminder Kind = "reminder"
+	KindAlarm    Kind = "alarm"
+)
+
+//A Timer is announced to the user once its time comes
+type Timer struct {
+	ID      int       `json:"id"`
+	Kind    Kind      `json:"kind"`
+	Message string    `json:"message,omitempty"`
+	FireAt  time.Time `json:"fire_at"`
+	Created time.Time `json:"created"`
+	//Language is the language the Timer was set in, and is announced in
+	Language string `json:"language,omitempty"`
+}
+
+//Describe returns a short description of the Timer that can be spoken to the user, in the language of the context
+func (t Timer) Describe(ctx context.Context) string {
+	switch t.Kind {
+	case KindReminder:
+		return i18n.Sprintf(ctx, "a reminder at %s to %s", t.FireAt.Format("15:04"), t.Message)
+	case KindAlarm:
+		return i18n.Sprintf(ctx, "an alarm at %s", t.FireAt.Format("15:04"))
+	default:
+		return i18n.Sprintf(ctx, "a timer of %s, ending at %s", t.Message, t.FireAt.Format("15:04"))
+	}
+}
+
+func (t Timer) announcement(ctx context.Context, late bool) string {
+	var text string
+	switch t.Kind {
+	case KindReminder:
+		text = i18n.Sprintf(ctx, "Reminder: %s.", t.Message)
+	case KindAlarm:
+		text = i18n.Sprintf(ctx, "Wake up! It is %s.", t.FireAt.Format("15:04"))
+	default:
+		text = i18n.Sprintf(ctx, "Your %s timer is done.", t.Message)
+	}
+	if late {
+		text += " " + i18n.Sprintf(ctx, "This was due at %s, while I was offline.", t.FireAt.Format("15:04"))
+	}
+	return text
+}
+
+//Service keeps the pending timers on disk and announces them when they fire
+type Service struct {
+	mu      sync.Mutex
+	path    string
+	timers  map[int]*Timer
+	pending map[int]*time.Timer
+	nextID  int
+
+	tts   *tts.Service
+	sms   *sms.Service
+	phone string
+}
+
+//New creates a new Service which stores the timers in the given file.
+//The timers already in the file are scheduled again, and the ones missed while PHAS was not running are announced right away.
+//When the phone number is not empty, each timer is also sent as an SMS.
+func New(path string, ttsService *tts.Service, smsService *sms.Service, phone string) (*Service, error) {
+	s := &Service{
+		path:    path,
+		timers:  map[int]*Timer{},
+		pending: map[int]*time.Timer{},
+		nextID:  1,
+		tts:     ttsService,
+		sms:     smsService,
+		phone:   phone,
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(b) > 0 {
+		var stored []*Timer
+		if err := json.Unmarshal(b, &stored); err != nil {
+			return nil, fmt.Errorf("invalid timers file %s: %w", path, err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, t := range stored {
+			s.timers[t.ID] = t
+			if t.ID >= s.nextID {
+				s.nextID = t.ID + 1
+			}
+			s.schedule(t)
+		}
+	}
+
+	return s, nil
+}
+
+// schedule starts the clock of the Timer. It must be called with the lock held.
+func (s *Service) schedule(t *Timer) {
+	wait := time.Until(t.FireAt)
+	late := wait < -time.Minute
+	if wait < 0 {
+		wait = 0
+	}
+
+	s.pending[t.ID] = time.AfterFunc(wait, func() {
+		s.fire(t, late)
+	})
+}
+
+func (s *Service) fire(t *Timer, late bool) {
+	s.mu.Lock()
+	if _, ok := s.timers[t.ID]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.timers, t.ID)
+	delete(s.pending, t.ID)
+	err := s.save()
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("failed to save the timers: %v\n", err)
+	}
+
+	// The Timer is announced in the language it was set in
+	ctx := i18n.WithLanguage(context.Background(), t.Language)
+	text := t.announcement(ctx, late)
+	log.Printf("%s %d fired: %s\n", t.Kind, t.ID, text)
+	s.tts.Speak(ctx, text)
+
+	if s.phone != "" {
+		if err := s.sms.SendSMS(s.phone, text); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// save writes the timers to disk. It must be called with the lock held.
+func (s *Service) save() error {
+	list := s.list()
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *Service) list() []*Timer {
+	var res []*Timer
+	for _, t := range s.timers {
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].FireAt.Before(res[j].FireAt)
+	})
+	return res
+}
+
+//Add creates a new Timer which fires at the given time, and is announced in the given language
+func (s *Service) Add(kind Kind, message string, fireAt time.Time, language string) (Timer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &Timer{
+		ID:       s.nextID,
+		Kind:     kind,
+		Message:  message,
+		FireAt:   fireAt,
+		Created:  time.Now(),
+		Language: language,
+	}
+	s.nextID++
+	s.timers[t.ID] = t
+	if err := s.save(); err != nil {
+		delete(s.timers, t.ID)
+		return Timer{}, err
+	}
+	s.schedule(t)
+
+	return *t, nil
+}
+
+//List returns the pending timers, the next one to fire first
+func (s *Service) List() []Timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []Timer
+	for _, t := range s.list() {
+		res = append(res, *t)
+	}
+	return res
+}
+
+//Cancel stops the next pending timer of the given kind, or all of them when all is true.
+//An empty kind matches any timer. It returns the cancelled timers.
+func (s *Service) Cancel(kind Kind, all bool) ([]Timer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []Timer
+	for _, t := range s.list() {
+		if kind != "" && t.Kind != kind {
+			continue
+		}
+		if p, ok := s.pending[t.ID]; ok {
+			p.Stop()
+			delete(s.pending, t.ID)
+		}
+		delete(s.timers, t.ID)
+		res = append(res, *t)
+		if !all {
+			break
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, s.save()
+}
@@ -0,0 +1,174 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package timers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlsniper/phas/tts"
+)
+
+// announcements collects what the timers say
+type announcements chan string
+
+func (a announcements) Write(p []byte) (int, error) {
+	a <- strings.TrimSpace(strings.TrimPrefix(string(p), "PHAS: "))
+	return len(p), nil
+}
+
+func (a announcements) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case text := <-a:
+		return text
+	case <-time.After(2 * time.Second):
+		t.Fatal("no timer fired")
+		return ""
+	}
+}
+
+func newService(t *testing.T, path string) (*Service, announcements) {
+	t.Helper()
+	said := make(announcements, 10)
+	s, err := New(path, tts.NewConsole(said), nil, "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = s.Cancel("", true)
+	})
+	return s, said
+}
+
+// stopClocks stops the timers of the Service without changing the file
+func stopClocks(s *Service) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.pending {
+		p.Stop()
+	}
+}
+
+func readFile(t *testing.T, path string) []Timer {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []Timer
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatalf("invalid timers file: %v", err)
+	}
+	return res
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timers.json")
+	s, _ := newService(t, path)
+
+	alarm, err := s.Add(KindAlarm, "", time.Now().Add(time.Hour), "en-US")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	reminder, err := s.Add(KindReminder, "call mom", time.Now().Add(2*time.Hour), "ro-RO")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	stored := readFile(t, path)
+	if len(stored) != 2 || stored[0].ID != alarm.ID || stored[1].ID != reminder.ID || stored[1].Language != "ro-RO" {
+		t.Fatalf("got the stored timers %+v", stored)
+	}
+
+	// PHAS restarts, and only the file is left
+	stopClocks(s)
+	s, _ = newService(t, path)
+
+	list := s.List()
+	if len(list) != 2 || list[0].ID != alarm.ID || list[1].Message != "call mom" || list[1].Language != "ro-RO" {
+		t.Errorf("got the restored timers %+v", list)
+	}
+	next, err := s.Add(KindTimer, "5 minutes", time.Now().Add(5*time.Minute), "")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if next.ID != reminder.ID+1 {
+		t.Errorf("got the ID %d after a restart, want %d", next.ID, reminder.ID+1)
+	}
+
+	cancelled, err := s.Cancel(KindReminder, false)
+	if err != nil || len(cancelled) != 1 || cancelled[0].ID != reminder.ID {
+		t.Errorf("Cancel returned %+v, %v, want the reminder", cancelled, err)
+	}
+	if stored := readFile(t, path); len(stored) != 2 {
+		t.Errorf("got %d stored timers after the cancel, want 2", len(stored))
+	}
+}
+
+func TestRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timers.json")
+	now := time.Now()
+	missed := now.Add(-10 * time.Minute)
+	stored := []Timer{
+		{ID: 4, Kind: KindReminder, Message: "water the plants", FireAt: missed, Created: now.Add(-time.Hour)},
+		{ID: 5, Kind: KindTimer, Message: "5 minutes", FireAt: now.Add(100 * time.Millisecond), Created: now.Add(-5 * time.Minute)},
+		{ID: 6, Kind: KindAlarm, FireAt: now.Add(time.Hour), Created: now},
+	}
+	if err := os.WriteFile(path, mustJSON(t, stored), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, said := newService(t, path)
+
+	// The timer missed while PHAS was not running fires right away, and says it's late
+	want := "Reminder: water the plants. This was due at " + missed.Format("15:04") + ", while I was offline."
+	if got := said.next(t); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := said.next(t), "Your 5 minutes timer is done."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if list := s.List(); len(list) != 1 || list[0].ID != 6 {
+		t.Errorf("got the pending timers %+v, want the alarm", list)
+	}
+	if stored := readFile(t, path); len(stored) != 1 || stored[0].ID != 6 {
+		t.Errorf("got the stored timers %+v, want the alarm", stored)
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timers.json")
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(path, nil, nil, ""); err == nil {
+		t.Error("New succeeded with an invalid file, want an error")
+	}
+}
+
+func mustJSON(t *testing.T, timers []Timer) []byte {
+	t.Helper()
+	b, err := json.Marshal(timers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
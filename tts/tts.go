@@ -15,19 +15,40 @@
 package tts
 
 import (
-	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"log"
+	"strings"
+	"sync"
 
 	"cloud.google.com/go/texttospeech/apiv1"
 	"github.com/hajimehoshi/oto"
 	texttospeechpb "google.golang.org/genproto/googleapis/cloud/texttospeech/v1"
+
+	"github.com/dlsniper/phas/i18n"
 )
 
 type config struct {
 	audioConfig *texttospeechpb.AudioConfig
-	voice       *texttospeechpb.VoiceSelectionParams
+	voices      []*texttospeechpb.VoiceSelectionParams
+}
+
+//voice returns the voice for the language of the context.
+//When there is none, GCP picks a voice for the language.
+func (c *config) voice(ctx context.Context) *texttospeechpb.VoiceSelectionParams {
+	language := i18n.Language(ctx)
+	for _, v := range c.voices {
+		if strings.EqualFold(v.LanguageCode, language) {
+			return v
+		}
+	}
+	for _, v := range c.voices {
+		if i18n.Same(v.LanguageCode, language) {
+			return v
+		}
+	}
+	return &texttospeechpb.VoiceSelectionParams{LanguageCode: language}
 }
 
 //Service processes the text to speech content transformation
@@ -35,14 +56,36 @@ type Service struct {
 	service *texttospeech.Client
 	config  *config
 	player  *oto.Player
+	console io.Writer
+
+	mu   sync.Mutex
+	stop chan struct{}
+	// playing is held while a speech is played, so that the speeches don't mix
+	playing sync.Mutex
 }
 
-//Speak will read the text back to the user
-func (s Service) Speak(ctx context.Context, text string) {
-	req := s.newRequest(text)
+// chunkSize is how much audio is played before checking if the speech was interrupted
+const chunkSize = 4096
+
+//Speak will read the text back to the user, after the speech already playing.
+//The speech ends early when the context is done or Stop is called.
+func (s *Service) Speak(ctx context.Context, text string) {
+	if ctx.Err() != nil {
+		return
+	}
+	if s.console != nil {
+		_, _ = fmt.Fprintf(s.console, "PHAS: %s\n", text)
+		return
+	}
+
+	stop := s.stopped()
+	req := s.newRequest(ctx, text)
 
 	resp, err := s.service.SynthesizeSpeech(ctx, &req)
 	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
 		log.Fatal(err)
 	}
 
@@ -50,35 +93,62 @@ func (s Service) Speak(ctx context.Context, text string) {
 		log.Fatalln("nil audio response from GCP...")
 	}
 
-	response := bytes.NewReader(resp.AudioContent)
-	readBytes := int64(0)
-	for {
-		//goland:noinspection GoShadowedVar
-		n, err := io.Copy(s.player, response)
-		readBytes += n
-		// It seems that sometimes the io.EOF is not enough to stop
-		// so we need to keep track of the read bytes...
-		if err == io.EOF || int(readBytes) >= len(resp.AudioContent) {
-			break
+	s.playing.Lock()
+	defer s.playing.Unlock()
+	audio := resp.AudioContent
+	for len(audio) > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		default:
+		}
+
+		chunk := audio
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
 		}
+		n, err := s.player.Write(chunk)
 		if err != nil {
 			log.Fatalln(err)
 		}
+		audio = audio[n:]
+	}
+}
+
+//Stop interrupts all the speech in progress, including the speeches waiting for their turn
+func (s *Service) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+	}
+	s.stop = make(chan struct{})
+}
+
+func (s *Service) stopped() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		s.stop = make(chan struct{})
 	}
+	return s.stop
 }
 
-func (s *Service) newRequest(text string) texttospeechpb.SynthesizeSpeechRequest {
+func (s *Service) newRequest(ctx context.Context, text string) texttospeechpb.SynthesizeSpeechRequest {
 	return texttospeechpb.SynthesizeSpeechRequest{
 		Input: &texttospeechpb.SynthesisInput{
 			InputSource: &texttospeechpb.SynthesisInput_Text{Text: text},
 		},
 		AudioConfig: s.config.audioConfig,
-		Voice:       s.config.voice,
+		Voice:       s.config.voice(ctx),
 	}
 }
 
-//New creates a new text to speech service
-func New(service *texttospeech.Client) *Service {
+//New creates a new text to speech service.
+//The voices map languages, such as "ro-RO", to the name of the voice used for them, such as "ro-RO-Wavenet-A".
+func New(service *texttospeech.Client, voices map[string]string) *Service {
 	playerCtx, err := oto.NewContext(24000, 1, 2, 8192)
 	if err != nil {
 		log.Fatalln(err)
@@ -86,17 +156,27 @@ func New(service *texttospeech.Client) *Service {
 
 	player := playerCtx.NewPlayer()
 
+	c := &config{
+		audioConfig: &texttospeechpb.AudioConfig{
+			AudioEncoding: texttospeechpb.AudioEncoding_LINEAR16,
+		},
+	}
+	for language, name := range voices {
+		c.voices = append(c.voices, &texttospeechpb.VoiceSelectionParams{
+			Name:         name,
+			LanguageCode: language,
+		})
+	}
+
 	return &Service{
 		service: service,
-		config: &config{
-			audioConfig: &texttospeechpb.AudioConfig{
-				AudioEncoding: texttospeechpb.AudioEncoding_LINEAR16,
-			},
-			voice: &texttospeechpb.VoiceSelectionParams{
-				Name:         "en-US-Wavenet-D",
-				LanguageCode: "en-US",
-			},
-		},
-		player: player,
+		config:  c,
+		player:  player,
 	}
 }
+
+//NewConsole creates a text to speech service that writes the text instead of speaking it.
+//It needs neither GCP credentials nor an audio device.
+func NewConsole(out io.Writer) *Service {
+	return &Service{console: out}
+}
@@ -0,0 +1,221 @@
+//    Copyright 2021 Florin Pățan
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package wled
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlsniper/phas/lights"
+)
+
+//Device is a WLED light, as set in the configuration file
+type Device struct {
+	Name string `json:"name"`
+	//Address of the light, such as "192.168.1.50"
+	Address string `json:"address"`
+}
+
+//Service controls the WLED lights through their JSON API
+type Service struct {
+	devices []Device
+	client  *http.Client
+}
+
+// state is the part of the WLED state which PHAS changes and reads
+type state struct {
+	On  bool `json:"on"`
+	Bri int  `json:"bri,omitempty"`
+	//Transition is in steps of 100ms
+	Transition int       `json:"transition,omitempty"`
+	Seg        []segment `json:"seg,omitempty"`
+	//V asks WLED to answer with its new state
+	V bool `json:"v,omitempty"`
+}
+
+type segment struct {
+	Col [][]int `json:"col,omitempty"`
+	//Fx is the effect, where 0 is a solid color
+	Fx int `json:"fx"`
+}
+
+//New creates a new WLED Service
+func New(devices []Device) *Service {
+	return &Service{
+		devices: devices,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+//Name of the backend
+func (s *Service) Name() string {
+	return "wled"
+}
+
+//Devices returns the WLED lights from the configuration
+func (s *Service) Devices(context.Context) ([]lights.Device, error) {
+	res := make([]lights.Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		res = append(res, lights.Device{Name: d.Name, Backend: s.Name()})
+	}
+	return res, nil
+}
+
+// find returns the light with the given name, or all of them for an empty name
+func (s *Service) find(name string) ([]Device, error) {
+	if name == "" {
+		return s.devices, nil
+	}
+	for _, d := range s.devices {
+		if strings.EqualFold(d.Name, name) {
+			return []Device{d}, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no WLED light called %s", name)
+}
+
+// wledState converts the change to the state sent to the lights
+func wledState(c lights.Change) (*state, error) {
+	res := &state{On: !c.Off, V: true}
+	if c.Transition > 0 {
+		res.Transition = int((c.Transition + 50*time.Millisecond) / (100 * time.Millisecond))
+	}
+	if c.Off {
+		return res, nil
+	}
+
+	res.Bri = int(c.Bri())
+	var rgb *[3]uint8
+	if c.Kelvin > 0 {
+		k := lights.KelvinRGB(c.Kelvin)
+		rgb = &k
+	}
+	if c.Color != "" {
+		color, err := lights.LookupColor(c.Color)
+		if err != nil {
+			return nil, err
+		}
+		rgb = &color.RGB
+	}
+	if rgb != nil {
+		res.Seg = []segment{{Col: [][]int{{int(rgb[0]), int(rgb[1]), int(rgb[2])}}}}
+	}
+	return res, nil
+}
+
+//Set changes the WLED light with the given name, or all of them for an empty name.
+//It returns the state after the change.
+func (s *Service) Set(ctx context.Context, name string, change lights.Change) (*lights.State, error) {
+	body, err := wledState(change)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*lights.State
+	for _, d := range devices {
+		var reply state
+		if err := s.call(ctx, http.MethodPost, d, body, &reply); err != nil {
+			return nil, err
+		}
+		st := &lights.State{Name: d.Name, On: reply.On, Light: true}
+		if st.On {
+			st.Brightness = (reply.Bri*100 + 127) / 255
+		}
+		states = append(states, st)
+	}
+
+	if name == "" {
+		return lights.Combine(states), nil
+	}
+	return states[0], nil
+}
+
+//Snapshot saves the state of the WLED light with the given name, or of all of them for an empty name,
+//and returns a function which restores it
+func (s *Service) Snapshot(ctx context.Context, name string) (func(context.Context) error, error) {
+	devices, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make([]map[string]interface{}, len(devices))
+	for idx, d := range devices {
+		var current struct {
+			On  bool            `json:"on"`
+			Bri int             `json:"bri"`
+			Seg json.RawMessage `json:"seg"`
+		}
+		if err := s.call(ctx, http.MethodGet, d, nil, &current); err != nil {
+			return nil, err
+		}
+		saved[idx] = map[string]interface{}{
+			"on":         current.On,
+			"bri":        current.Bri,
+			"seg":        current.Seg,
+			"transition": 0,
+		}
+	}
+
+	return func(ctx context.Context) error {
+		var firstErr error
+		for idx, d := range devices {
+			if err := s.call(ctx, http.MethodPost, d, saved[idx], nil); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}, nil
+}
+
+// call sends the body, if any, to the state of the light, and decodes its answer into reply, if any
+func (s *Service) call(ctx context.Context, method string, d Device, body, reply interface{}) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+d.Address+"/json/state", &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("the WLED light %s is not available: %w", d.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the WLED light %s answered with %s", d.Name, resp.Status)
+	}
+
+	if reply == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("invalid answer from the WLED light %s: %w", d.Name, err)
+	}
+	return nil
+}